@@ -22,8 +22,16 @@ import (
 type fileConfig struct {
     Server           string        `json:"server"`
     DeregisterOnExit bool          `json:"deregister_on_exit"`
+    Drain            string        `json:"drain"` // 退出前维护模式排空时长，如 "5s"
+    Node             *fileNode     `json:"node"`  // 节点定义（可选，覆盖 -node 参数）
     Services         []fileService `json:"services"`
 }
+type fileNode struct {
+    Name    string            `json:"name"`
+    Address string            `json:"address"`
+    Meta    map[string]string `json:"meta"`
+    Checks  []api.CheckDef    `json:"checks"`
+}
 type fileService struct {
     Namespace string            `json:"ns"`
     Service   string            `json:"service"`
@@ -31,6 +39,7 @@ type fileService struct {
     Address   string            `json:"address"`
     Addr      string            `json:"addr"` // 别名
     Port      int               `json:"port"`
+    Zone      string            `json:"zone"`
     Tags      []string          `json:"tags"`
     Meta      map[string]string `json:"meta"`
     Checks    []api.CheckDef    `json:"checks"`
@@ -38,9 +47,9 @@ type fileService struct {
 
 func main() {
     var cfgPath string
-    var serverHTTP, ns, svc, id, addr string
+    var serverHTTP, ns, svc, id, addr, zone, nodeName string
     var port int
-    var ttlStr string
+    var ttlStr, drainStr string
     var dereg bool
     flag.StringVar(&cfgPath, "config", "", "JSON 配置文件路径，或包含多个 JSON 的目录")
     flag.StringVar(&serverHTTP, "server", "http://127.0.0.1:8500", "Server 的 HTTP 地址，例如 http://127.0.0.1:8500（配置文件可覆盖）")
@@ -48,58 +57,81 @@ func main() {
     flag.StringVar(&svc, "service", "demo", "服务名（单服务模式）")
     flag.StringVar(&id, "id", "", "实例 ID（可选，单服务模式）")
     flag.StringVar(&addr, "addr", "127.0.0.1", "对外发布的地址（单服务模式）")
+    flag.StringVar(&zone, "zone", "", "可用区（单服务模式，可选）")
+    flag.StringVar(&nodeName, "node", "", "节点名（为空则不注册节点；配置文件可覆盖）")
     flag.IntVar(&port, "port", 800, "服务端口（单服务模式）")
     flag.StringVar(&ttlStr, "ttl", "15s", "TTL（单服务模式，未在 checks 声明时生效）")
+    flag.StringVar(&drainStr, "drain", "0s", "退出前先进入维护模式并等待的时长（配置文件可覆盖）")
     flag.BoolVar(&dereg, "deregister", true, "进程退出时自动从 server 注销（配置文件可覆盖）")
     flag.Parse()
 
+    drain, err := time.ParseDuration(drainStr)
+    if err != nil {
+        log.Fatalf("bad drain: %v", err)
+    }
+
     ctx, cancel := signalContext()
     defer cancel()
 
-    // 配置文件模式：可以同时注册多个服务，并支持多种检查。
+    node := agent.NodeConfig{ServerHTTP: serverHTTP, Name: nodeName, Address: addr, DeregisterOnExit: dereg}
+    var cfgs []agent.Config
     if cfgPath != "" {
-        ags, err := loadAgentsFromPath(cfgPath, serverHTTP, dereg)
+        // 配置文件模式：可以同时注册多个服务，并支持多种检查。
+        var fn *fileNode
+        cfgs, fn, err = loadConfigsFromPath(cfgPath, serverHTTP, dereg, drain)
         if err != nil {
             log.Fatalf("加载配置失败: %v", err)
         }
-        if len(ags) == 0 {
+        if len(cfgs) == 0 {
             log.Fatalf("未在 %s 中发现任何服务配置", cfgPath)
         }
-        // 并发运行多个 Agent
-        errCh := make(chan error, len(ags))
-        for _, a := range ags {
-            go func(a *agent.Agent) {
-                errCh <- a.Run(ctx)
-            }(a)
+        node.ServerHTTP = cfgs[0].ServerHTTP
+        if fn != nil {
+            node.Name = defaultIfEmpty(fn.Name, node.Name)
+            node.Address = defaultIfEmpty(fn.Address, node.Address)
+            node.Meta = fn.Meta
+            node.Checks = fn.Checks
         }
-        // 等待任一出错或收到退出信号
-        select {
-        case err := <-errCh:
-            if err != nil {
-                log.Fatalf("agent error: %v", err)
-            }
-        case <-ctx.Done():
+    } else {
+        // 单服务兼容模式：仅 TTL 检查
+        ttl, err := time.ParseDuration(ttlStr)
+        if err != nil {
+            log.Fatalf("bad ttl: %v", err)
         }
-        return
+        cfgs = []agent.Config{{
+            ServerHTTP:       serverHTTP,
+            Namespace:        ns,
+            Service:          svc,
+            ID:               id,
+            Address:          addr,
+            Port:             port,
+            Zone:             zone,
+            TTL:              ttl,
+            DeregisterOnExit: dereg,
+            DrainTimeout:     drain,
+        }}
     }
 
-    // 单服务兼容模式：仅 TTL 检查
-    ttl, err := time.ParseDuration(ttlStr)
-    if err != nil {
-        log.Fatalf("bad ttl: %v", err)
+    // 节点与各服务 Agent 并发运行；实例通过 Node 字段挂到节点上
+    var runners []func(context.Context) error
+    if node.Name != "" {
+        runners = append(runners, agent.NewNodeAgent(node).Run)
     }
-    a := agent.New(agent.Config{
-        ServerHTTP:       serverHTTP,
-        Namespace:        ns,
-        Service:          svc,
-        ID:               id,
-        Address:          addr,
-        Port:             port,
-        TTL:              ttl,
-        DeregisterOnExit: dereg,
-    })
-    if err := a.Run(ctx); err != nil {
-        log.Fatalf("agent error: %v", err)
+    for _, cfg := range cfgs {
+        cfg.Node = node.Name
+        runners = append(runners, agent.New(cfg).Run)
+    }
+    errCh := make(chan error, len(runners))
+    for _, run := range runners {
+        go func(run func(context.Context) error) {
+            errCh <- run(ctx)
+        }(run)
+    }
+    // 等待全部 Agent 退出（收到信号后各 Agent 会完成排空/注销再返回）；任一出错即退出
+    for range runners {
+        if err := <-errCh; err != nil {
+            log.Fatalf("agent error: %v", err)
+        }
     }
 }
 
@@ -114,17 +146,17 @@ func signalContext() (context.Context, context.CancelFunc) {
     return ctx, cancel
 }
 
-// loadAgentsFromPath 从文件或目录加载 JSON 配置，并构造多个 Agent。
-func loadAgentsFromPath(path string, defaultServer string, defaultDeregister bool) ([]*agent.Agent, error) {
+// loadConfigsFromPath 从文件或目录加载 JSON 配置，返回各服务的 Agent 配置及节点定义（多个文件定义节点时以后者为准）。
+func loadConfigsFromPath(path string, defaultServer string, defaultDeregister bool, defaultDrain time.Duration) ([]agent.Config, *fileNode, error) {
     st, err := os.Stat(path)
     if err != nil {
-        return nil, err
+        return nil, nil, err
     }
     var files []string
     if st.IsDir() {
         entries, err := os.ReadDir(path)
         if err != nil {
-            return nil, err
+            return nil, nil, err
         }
         for _, e := range entries {
             if e.IsDir() {
@@ -138,46 +170,61 @@ func loadAgentsFromPath(path string, defaultServer string, defaultDeregister boo
     } else {
         files = []string{path}
     }
-    var res []*agent.Agent
+    var res []agent.Config
+    var node *fileNode
     for _, f := range files {
-        ags, err := loadAgentsFromFile(f, defaultServer, defaultDeregister)
+        cfgs, fn, err := loadConfigsFromFile(f, defaultServer, defaultDeregister, defaultDrain)
         if err != nil {
-            return nil, fmt.Errorf("%s: %w", f, err)
+            return nil, nil, fmt.Errorf("%s: %w", f, err)
+        }
+        res = append(res, cfgs...)
+        if fn != nil {
+            node = fn
         }
-        res = append(res, ags...)
     }
-    return res, nil
+    return res, node, nil
 }
 
-// loadAgentsFromFile 既支持顶层含 services 的聚合文件，也支持单服务文件。
-func loadAgentsFromFile(file string, defaultServer string, defaultDeregister bool) ([]*agent.Agent, error) {
+// loadConfigsFromFile 既支持顶层含 services 的聚合文件，也支持单服务文件。
+func loadConfigsFromFile(file string, defaultServer string, defaultDeregister bool, defaultDrain time.Duration) ([]agent.Config, *fileNode, error) {
     f, err := os.Open(file)
     if err != nil {
-        return nil, err
+        return nil, nil, err
     }
     defer f.Close()
     b, err := io.ReadAll(f)
     if err != nil {
-        return nil, err
+        return nil, nil, err
     }
     // 先尝试聚合结构
     var fc fileConfig
-    if err := json.Unmarshal(b, &fc); err == nil && (len(fc.Services) > 0 || fc.Server != "") {
+    if err := json.Unmarshal(b, &fc); err == nil && (len(fc.Services) > 0 || fc.Server != "" || fc.Node != nil) {
         server := defaultIfEmpty(fc.Server, defaultServer)
         dereg := fc.DeregisterOnExit || defaultDeregister
-        var out []*agent.Agent
+        drain := defaultDrain
+        if fc.Drain != "" {
+            d, err := time.ParseDuration(fc.Drain)
+            if err != nil {
+                return nil, nil, fmt.Errorf("bad drain: %w", err)
+            }
+            drain = d
+        }
+        var out []agent.Config
         for _, s := range fc.Services {
-            out = append(out, agent.New(convertFileService(server, dereg, s)))
+            cfg := convertFileService(server, dereg, s)
+            cfg.DrainTimeout = drain
+            out = append(out, cfg)
         }
-        return out, nil
+        return out, fc.Node, nil
     }
     // 尝试单服务结构（直接是 fileService）
     var s fileService
     if err := json.Unmarshal(b, &s); err != nil {
-        return nil, fmt.Errorf("不支持的 JSON 结构: %w", err)
+        return nil, nil, fmt.Errorf("不支持的 JSON 结构: %w", err)
     }
     cfg := convertFileService(defaultServer, defaultDeregister, s)
-    return []*agent.Agent{agent.New(cfg)}, nil
+    cfg.DrainTimeout = defaultDrain
+    return []agent.Config{cfg}, nil, nil
 }
 
 func convertFileService(server string, dereg bool, s fileService) agent.Config {
@@ -192,6 +239,7 @@ func convertFileService(server string, dereg bool, s fileService) agent.Config {
         ID:               s.ID,
         Address:          address,
         Port:             s.Port,
+        Zone:             s.Zone,
         Tags:             s.Tags,
         Meta:             s.Meta,
         Checks:           s.Checks,
@@ -9,24 +9,31 @@ import (
 	"syscall"
 	"time"
 
+	"sider/internal/api"
 	"sider/internal/server"
 )
 
 func main() {
-	var httpAddr string
+	var httpAddr, httpAdvertise string
 	var raftID, raftBind, raftDir string
 	var bootstrap bool
+	var flapThreshold, checkOutputMax int
+	var flapWindow time.Duration
 	flag.StringVar(&httpAddr, "http", ":8500", "HTTP 监听地址，如 :8500 或 127.0.0.1:8500")
+	flag.StringVar(&httpAdvertise, "http-advertise", "", "对集群其他节点公布的 HTTP 地址（host:port），用于写请求转发；留空则自动推导")
 	flag.StringVar(&raftID, "raft-id", "node1", "Raft 节点 ID（集群内唯一）")
 	flag.StringVar(&raftBind, "raft-bind", "127.0.0.1:8501", "Raft 监听地址（host:port）")
 	flag.StringVar(&raftDir, "raft-dir", "data/raft", "Raft 数据目录")
 	flag.BoolVar(&bootstrap, "raft-bootstrap", true, "是否作为引导节点（首次启动单节点集群）")
+	flag.IntVar(&flapThreshold, "flap-threshold", 0, "抖动判定：窗口内检查状态切换超过该次数即视为抖动（0 使用默认值 5）")
+	flag.DurationVar(&flapWindow, "flap-window", 0, "抖动判定窗口（0 使用默认值 10m）")
+	flag.IntVar(&checkOutputMax, "check-output-max", api.DefaultCheckOutputMax, "检查输出与说明的最大字节数，超出部分在写入 Raft 日志前截断")
 	flag.Parse()
 
 	ctx, cancel := signalContext()
 	defer cancel()
 
-	srv := &server.Server{HTTPAddr: httpAddr, RaftID: raftID, RaftBind: raftBind, RaftDir: raftDir, Bootstrap: bootstrap}
+	srv := &server.Server{HTTPAddr: httpAddr, HTTPAdvertise: httpAdvertise, RaftID: raftID, RaftBind: raftBind, RaftDir: raftDir, Bootstrap: bootstrap, FlapThreshold: flapThreshold, FlapWindow: flapWindow, CheckOutputMax: checkOutputMax}
 	if err := srv.Run(ctx); err != nil {
 		log.Fatalf("server exited with error: %v", err)
 	}
@@ -10,6 +10,7 @@ import (
     "log"
     "net"
     "net/http"
+    "net/url"
     "os"
     "os/exec"
     "strings"
@@ -31,11 +32,14 @@ type Config struct {
     ID               string        // 实例 ID（留空将自动生成）
     Address          string        // 对外地址
     Port             int           // 服务端口
+    Node             string        // 所属节点名（可选，见 NodeAgent）
+    Zone             string        // 可用区（可选，亦可通过 Meta["zone"] 指定）
     Tags             []string      // 标签
     Meta             map[string]string
     TTL              time.Duration // 兼容旧参数：若 >0 且未在 Checks 中显式声明 TTL，则自动添加
     Checks           []api.CheckDef
     DeregisterOnExit bool // 退出时调用服务端注销接口
+    DrainTimeout     time.Duration // 退出前先进入维护模式并等待该时长，让客户端摘除流量（0 表示不等待）
 }
 
 type Agent struct {
@@ -43,6 +47,7 @@ type Agent struct {
     client       *http.Client
     checkIDs     []string
     loopCancels  []context.CancelFunc // 各检查循环的取消函数
+    defaultTTL   time.Duration        // 服务级配置项中的默认 TTL（未声明 TTL 的检查由服务端按此过期）
 }
 
 func New(cfg Config) *Agent {
@@ -62,17 +67,7 @@ func (a *Agent) Run(ctx context.Context) error {
         a.cfg.ID = fmt.Sprintf("%s-%s-%d", a.cfg.Service, host, a.cfg.Port)
     }
 
-    // 若未显式声明 TTL 检查但保留了旧 TTL 参数，则补上一条 TTL 检查。
-    hasTTL := false
-    for _, c := range a.cfg.Checks {
-        if strings.EqualFold(c.Type, string("ttl")) {
-            hasTTL = true
-            break
-        }
-    }
-    if !hasTTL && a.cfg.TTL > 0 {
-        a.cfg.Checks = append(a.cfg.Checks, api.CheckDef{Type: "ttl", TTL: a.cfg.TTL.String()})
-    }
+    a.cfg.Checks = withDefaultTTL(a.cfg.Checks, a.cfg.TTL)
 
     if err := a.register(ctx); err != nil {
         return err
@@ -86,6 +81,15 @@ func (a *Agent) Run(ctx context.Context) error {
     for _, cancel := range a.loopCancels {
         cancel()
     }
+    if a.cfg.DrainTimeout > 0 {
+        // 进入维护模式，使实例被健康查询排除后再退出
+        if err := a.SetMaintenance(context.Background(), true, "agent shutting down"); err != nil {
+            log.Printf("进入维护模式失败: %v", err)
+        } else {
+            log.Printf("已进入维护模式，等待 %s 排空流量", a.cfg.DrainTimeout)
+            time.Sleep(a.cfg.DrainTimeout)
+        }
+    }
     if a.cfg.DeregisterOnExit {
         _ = a.deregister(context.Background())
     }
@@ -100,6 +104,8 @@ func (a *Agent) register(ctx context.Context) error {
         ID:        a.cfg.ID,
         Address:   a.cfg.Address,
         Port:      a.cfg.Port,
+        Node:      a.cfg.Node,
+        Zone:      a.cfg.Zone,
         Tags:      a.cfg.Tags,
         Meta:      mergeStringMap(map[string]string{"agent": "sider"}, a.cfg.Meta),
         Checks:    a.cfg.Checks,
@@ -121,9 +127,34 @@ func (a *Agent) register(ctx context.Context) error {
     _ = json.NewDecoder(resp.Body).Decode(&rr)
     a.checkIDs = rr.CheckIDs
     log.Printf("已注册实例 %s (checks=%v) index=%d", rr.InstanceID, rr.CheckIDs, rr.Index)
+    a.defaultTTL = a.fetchDefaultTTL(ctx)
     return nil
 }
 
+// fetchDefaultTTL 读取服务级配置项中的默认 TTL，使续约间隔与服务端实际使用的 TTL 一致；
+// 未配置或读取失败时返回 0。
+func (a *Agent) fetchDefaultTTL(ctx context.Context) time.Duration {
+    u := fmt.Sprintf("%s/v1/config/service-defaults/%s/%s?stale",
+        stringsTrimTrailingSlash(a.cfg.ServerHTTP), url.PathEscape(a.cfg.Namespace), url.PathEscape(a.cfg.Service))
+    req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+    resp, err := a.client.Do(req)
+    if err != nil {
+        return 0
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return 0
+    }
+    var d struct {
+        TTL string `json:"TTL"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&d); err != nil || d.TTL == "" {
+        return 0
+    }
+    ttl, _ := time.ParseDuration(d.TTL)
+    return ttl
+}
+
 // deregister 注销当前实例（可选）。
 func (a *Agent) deregister(ctx context.Context) error {
     if a.cfg.ID == "" {
@@ -144,6 +175,28 @@ func (a *Agent) deregister(ctx context.Context) error {
     return nil
 }
 
+// SetMaintenance 开启或关闭当前实例的维护模式。
+func (a *Agent) SetMaintenance(ctx context.Context, enable bool, reason string) error {
+    q := url.Values{}
+    q.Set("enable", strconv.FormatBool(enable))
+    q.Set("reason", reason)
+    q.Set("ns", a.cfg.Namespace)
+    q.Set("service", a.cfg.Service)
+    u := fmt.Sprintf("%s/v1/agent/service/maintenance/%s?%s",
+        stringsTrimTrailingSlash(a.cfg.ServerHTTP), url.PathEscape(a.cfg.ID), q.Encode())
+    req, _ := http.NewRequestWithContext(ctx, http.MethodPut, u, nil)
+    resp, err := a.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        b, _ := io.ReadAll(resp.Body)
+        return fmt.Errorf("maintenance failed: %s", string(b))
+    }
+    return nil
+}
+
 // startCheckLoops 根据返回的 checkIDs 与定义的 Checks 启动对应的循环。
 func (a *Agent) startCheckLoops(ctx context.Context) {
     // 按请求顺序返回的 CheckIDs 与 a.cfg.Checks 一一对应。
@@ -157,8 +210,11 @@ func (a *Agent) startCheckLoops(ctx context.Context) {
         a.loopCancels = append(a.loopCancels, cancel)
         switch strings.ToLower(def.Type) {
         case "ttl":
-            // TTL：定时续约（2/3 TTL）。若入参未指定 TTL 字符串，回退至 a.cfg.TTL。
+            // TTL：定时续约（2/3 TTL）。若入参未指定 TTL 字符串，依次回退至服务级默认 TTL、a.cfg.TTL。
             ttl := a.cfg.TTL
+            if a.defaultTTL > 0 {
+                ttl = a.defaultTTL
+            }
             if def.TTL != "" {
                 if d, err := time.ParseDuration(def.TTL); err == nil && d > 0 {
                     ttl = d
@@ -253,12 +309,13 @@ func (a *Agent) runHTTPCheckOnce(ctx context.Context, client *http.Client, url,
     }
     defer resp.Body.Close()
     // 2xx 和 3xx 视为通过，其余失败（简化逻辑）。
+    output := fmt.Sprintf("HTTP GET %s: %s", url, resp.Status)
     if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-        _ = a.reportCheck(ctx, checkID, "pass", "")
+        _ = a.reportCheck(ctx, checkID, "pass", output)
     } else if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-        _ = a.reportCheck(ctx, checkID, "warn", fmt.Sprintf("status=%d", resp.StatusCode))
+        _ = a.reportCheck(ctx, checkID, "warn", output)
     } else {
-        _ = a.reportCheck(ctx, checkID, "fail", fmt.Sprintf("status=%d", resp.StatusCode))
+        _ = a.reportCheck(ctx, checkID, "fail", output)
     }
 }
 
@@ -280,7 +337,7 @@ func (a *Agent) tcpCheckLoop(ctx context.Context, checkID string, def api.CheckD
             return
         }
         _ = conn.Close()
-        _ = a.reportCheck(ctx, checkID, "pass", "")
+        _ = a.reportCheck(ctx, checkID, "pass", fmt.Sprintf("TCP connect %s: Success", target))
     }
     run()
     for {
@@ -312,8 +369,12 @@ func (a *Agent) cmdCheckLoop(ctx context.Context, checkID string, def api.CheckD
         cmd := exec.CommandContext(cctx, "bash", "-lc", cmdline)
         out, err := cmd.CombinedOutput()
         if err != nil {
-            // 若为超时，err 会包含 context deadline exceeded
-            _ = a.reportCheck(ctx, checkID, "fail", string(out))
+            // 若为超时，err 会包含 context deadline exceeded；附上错误便于区分超时与非零退出码
+            msg := err.Error()
+            if len(out) > 0 {
+                msg = string(out) + "\n" + msg
+            }
+            _ = a.reportCheck(ctx, checkID, "fail", msg)
             return
         }
         _ = a.reportCheck(ctx, checkID, "pass", string(out))
@@ -332,12 +393,15 @@ func (a *Agent) cmdCheckLoop(ctx context.Context, checkID string, def api.CheckD
 func (a *Agent) reportCheck(ctx context.Context, checkID string, action string, output string) error {
     // action: pass|warn|fail
     url := fmt.Sprintf("%s/v1/agent/check/%s/%s", stringsTrimTrailingSlash(a.cfg.ServerHTTP), action, checkID)
+    // 输出作为请求体上报（服务端超出上限时截断）；TTL 续约不带输出
     var body io.Reader
     if output != "" {
-        // 简化：将输出通过 query 忽略；当前服务器端未接收输出体，这里仅保留接口位置
-        _ = output
+        body = strings.NewReader(output)
     }
     req, _ := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
+    if body != nil {
+        req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+    }
     resp, err := a.client.Do(req)
     if err != nil {
         return err
@@ -350,6 +414,19 @@ func (a *Agent) reportCheck(ctx context.Context, checkID string, action string,
     return nil
 }
 
+// withDefaultTTL 若未显式声明 TTL 检查但保留了旧 TTL 参数，则补上一条 TTL 检查。
+func withDefaultTTL(checks []api.CheckDef, ttl time.Duration) []api.CheckDef {
+    for _, c := range checks {
+        if strings.EqualFold(c.Type, "ttl") {
+            return checks
+        }
+    }
+    if ttl > 0 {
+        checks = append(checks, api.CheckDef{Type: "ttl", TTL: ttl.String()})
+    }
+    return checks
+}
+
 func stringsTrimTrailingSlash(s string) string {
     for len(s) > 0 && s[len(s)-1] == '/' {
         s = s[:len(s)-1]
@@ -0,0 +1,124 @@
+package agent
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "time"
+
+    "sider/internal/api"
+)
+
+// NodeConfig 为节点 Agent 配置。
+// 节点代表运行 Agent 的主机；节点级检查（如磁盘、负载）失败时，该节点上的全部实例都会被视为不健康。
+type NodeConfig struct {
+    ServerHTTP       string // 例如 http://127.0.0.1:8500
+    Name             string // 节点名（留空使用主机名）
+    Address          string // 节点地址
+    Meta             map[string]string
+    TTL              time.Duration // 若 >0 且未在 Checks 中显式声明 TTL，则自动添加
+    Checks           []api.CheckDef
+    DeregisterOnExit bool // 退出时调用服务端注销节点
+}
+
+// NodeAgent 负责注册节点并执行节点级检查。
+// 检查循环复用 Agent 的实现：内部 Agent 只承载检查，不注册实例。
+type NodeAgent struct {
+    cfg    NodeConfig
+    checks *Agent
+}
+
+func NewNodeAgent(cfg NodeConfig) *NodeAgent {
+    return &NodeAgent{cfg: cfg}
+}
+
+// Name 返回节点名（Run 之前为空时使用主机名）。
+func (n *NodeAgent) Name() string {
+    if n.cfg.Name == "" {
+        n.cfg.Name, _ = os.Hostname()
+    }
+    return n.cfg.Name
+}
+
+// Run 注册节点并执行节点级检查循环；直到 ctx 取消。
+func (n *NodeAgent) Run(ctx context.Context) error {
+    if n.cfg.ServerHTTP == "" {
+        return errors.New("missing ServerHTTP")
+    }
+    if n.Name() == "" {
+        return errors.New("missing node Name")
+    }
+    n.cfg.Checks = withDefaultTTL(n.cfg.Checks, n.cfg.TTL)
+    n.checks = New(Config{
+        ServerHTTP: n.cfg.ServerHTTP,
+        Address:    n.cfg.Address,
+        TTL:        n.cfg.TTL,
+        Checks:     n.cfg.Checks,
+    })
+
+    if err := n.register(ctx); err != nil {
+        return err
+    }
+    n.checks.startCheckLoops(ctx)
+
+    <-ctx.Done()
+    for _, cancel := range n.checks.loopCancels {
+        cancel()
+    }
+    if n.cfg.DeregisterOnExit {
+        _ = n.deregister(context.Background())
+    }
+    return nil
+}
+
+// register 将节点及节点级检查注册到服务端。
+func (n *NodeAgent) register(ctx context.Context) error {
+    req := api.RegisterNodeRequest{
+        Name:    n.cfg.Name,
+        Address: n.cfg.Address,
+        Meta:    n.cfg.Meta,
+        Checks:  n.cfg.Checks,
+    }
+    body, _ := json.Marshal(req)
+    u := fmt.Sprintf("%s/v1/agent/node/register", stringsTrimTrailingSlash(n.cfg.ServerHTTP))
+    httpReq, _ := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(body))
+    httpReq.Header.Set("Content-Type", "application/json")
+    resp, err := n.checks.client.Do(httpReq)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        b, _ := io.ReadAll(resp.Body)
+        return fmt.Errorf("register node failed: %s", string(b))
+    }
+    var rr api.RegisterResponse
+    _ = json.NewDecoder(resp.Body).Decode(&rr)
+    n.checks.checkIDs = rr.CheckIDs
+    log.Printf("已注册节点 %s (checks=%v) index=%d", n.cfg.Name, rr.CheckIDs, rr.Index)
+    return nil
+}
+
+// deregister 注销当前节点。
+func (n *NodeAgent) deregister(ctx context.Context) error {
+    u := fmt.Sprintf("%s/v1/agent/node/deregister/%s",
+        stringsTrimTrailingSlash(n.cfg.ServerHTTP), url.PathEscape(n.cfg.Name))
+    req, _ := http.NewRequestWithContext(ctx, http.MethodPut, u, nil)
+    resp, err := n.checks.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        b, _ := io.ReadAll(resp.Body)
+        return fmt.Errorf("deregister node failed: %s", string(b))
+    }
+    return nil
+}
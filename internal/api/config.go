@@ -0,0 +1,76 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "sider/internal/registry"
+)
+
+// config.go - 服务级配置项接口：/v1/config/service-defaults[/{ns}/{name}]
+
+func (h *HTTPServer) handleListServiceDefaults(w http.ResponseWriter, r *http.Request) {
+    list, idx, err := h.Reg.ListServiceDefaults(r.Context(), r.URL.Query().Get("ns"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(list)
+}
+
+func (h *HTTPServer) handleServiceDefaults(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/config/service-defaults/{ns}/{name}
+    rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/config/service-defaults/"), "/")
+    ns, name, ok := strings.Cut(rest, "/")
+    if !ok || ns == "" || name == "" || strings.Contains(name, "/") {
+        http.Error(w, "path must be /v1/config/service-defaults/{ns}/{name}", http.StatusBadRequest)
+        return
+    }
+    var idx uint64
+    var err error
+    switch r.Method {
+    case http.MethodGet:
+        var d registry.ServiceDefaults
+        d, idx, err = h.Reg.GetServiceDefaults(r.Context(), ns, name)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(d)
+        return
+    case http.MethodPut, http.MethodPost:
+        var req ServiceDefaultsRequest
+        if r.ContentLength != 0 {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+                return
+            }
+        }
+        d := registry.ServiceDefaults{
+            Namespace: ns,
+            Name:      name,
+            Protocol:  req.Protocol,
+            Meta:      req.Meta,
+            Weights:   registry.Weights{Passing: req.Weights.Passing, Warning: req.Weights.Warning},
+            TTLRaw:    req.TTL,
+        }
+        idx, err = h.Reg.UpsertServiceDefaults(r.Context(), d)
+    case http.MethodDelete:
+        idx, err = h.Reg.DeleteServiceDefaults(r.Context(), ns, name)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.WriteHeader(http.StatusOK)
+}
@@ -5,11 +5,15 @@ import (
     "encoding/json"
     "errors"
     "fmt"
+    "io"
     "log"
     "net/http"
+    "net/http/httputil"
+    "net/url"
     "strconv"
     "strings"
     "time"
+    "unicode/utf8"
 
     "sider/internal/registry"
 )
@@ -20,26 +24,89 @@ type HTTPServer struct {
     Addr     string
     srv      *http.Server
     Joiner   Joiner // 可选：用于集群加入
-    IsLeader func() bool
+    Leader   LeaderLocator // 可选：非 Leader 节点据此将写请求转发给 Leader
+    Consistency ReadConsistency // 可选：读一致性模式所需的集群状态
+    CheckOutputMax int // 检查输出与说明的最大字节数，超出部分在写入 Raft 日志前截断（0 使用默认值）
 }
 
+// serverWriteTimeout 为响应写入超时；长轮询的等待时间须小于该值（见 maxBlockingWait）。
+const serverWriteTimeout = 15 * time.Second
+
+// maxBlockingWait 为长轮询 ?wait= 的上限，为写响应预留余量，避免阻塞查询触发写超时。
+const maxBlockingWait = serverWriteTimeout - 5*time.Second
+
+// DefaultCheckOutputMax 为检查输出的默认最大字节数。
+const DefaultCheckOutputMax = 4096
+
+// ReadConsistency 提供读一致性模式所需的集群状态。
+type ReadConsistency interface {
+    // VerifyLeader 经多数派确认本节点仍是 Leader，用于 consistent 读。
+    VerifyLeader() error
+    // KnownLeader 返回集群当前是否有已知 Leader。
+    KnownLeader() bool
+    // LastContact 返回距上次与 Leader 通信的时长；Leader 自身为 0。
+    LastContact() time.Duration
+}
+
+// LeaderLocator 定位当前 Leader 的 HTTP 地址。
+type LeaderLocator interface {
+    // LeaderHTTPAddr 返回 Leader 的 HTTP 地址；本节点即 Leader 时 isSelf 为 true。
+    LeaderHTTPAddr() (addr string, isSelf bool)
+}
+
+// headerForwarded 标记已被转发过的请求，避免在 Leader 切换期间循环转发。
+const headerForwarded = "X-Sider-Forwarded"
+
 func (h *HTTPServer) Start(ctx context.Context) error {
     mux := http.NewServeMux()
-    mux.HandleFunc("/v1/agent/service/register", h.handleRegister)
-    mux.HandleFunc("/v1/agent/service/deregister/", h.handleDeregisterByPath) // 路径式注销
-    mux.HandleFunc("/v1/agent/service/deregister", h.handleDeregisterJSON)    // JSON 请求体注销
-    mux.HandleFunc("/v1/agent/check/pass/", h.handleCheckPass)
-    mux.HandleFunc("/v1/agent/check/warn/", h.handleCheckWarn)
-    mux.HandleFunc("/v1/agent/check/fail/", h.handleCheckFail)
-    mux.HandleFunc("/v1/catalog/services", h.handleCatalogServices)
-    mux.HandleFunc("/v1/health/service/", h.handleHealthService)
-    mux.HandleFunc("/v1/raft/join", h.handleRaftJoin)
+    // 写接口：在非 Leader 节点上透明转发给 Leader
+    mux.HandleFunc("/v1/agent/service/register", h.forwardWrites(h.handleRegister))
+    mux.HandleFunc("/v1/agent/service/deregister/", h.forwardWrites(h.handleDeregisterByPath)) // 路径式注销
+    mux.HandleFunc("/v1/agent/service/deregister", h.forwardWrites(h.handleDeregisterJSON))    // JSON 请求体注销
+    mux.HandleFunc("/v1/agent/service/maintenance/", h.forwardWrites(h.handleMaintenance)) // 实例维护
+    mux.HandleFunc("/v1/agent/service/maintenance", h.forwardWrites(h.handleMaintenance))  // 服务维护（全部实例）
+    mux.HandleFunc("/v1/agent/node/register", h.forwardWrites(h.handleRegisterNode))
+    mux.HandleFunc("/v1/agent/node/deregister/", h.forwardWrites(h.handleDeregisterNode))
+    mux.HandleFunc("/v1/agent/check/pass/", h.forwardWrites(h.handleCheckPass))
+    mux.HandleFunc("/v1/agent/check/warn/", h.forwardWrites(h.handleCheckWarn))
+    mux.HandleFunc("/v1/agent/check/fail/", h.forwardWrites(h.handleCheckFail))
+    // 读接口：支持 ?stale / 默认 / ?consistent 三种一致性模式
+    mux.HandleFunc("/v1/catalog/services", h.forwardReads(h.handleCatalogServices))
+    mux.HandleFunc("/v1/catalog/nodes", h.forwardReads(h.handleCatalogNodes))
+    mux.HandleFunc("/v1/catalog/node/", h.forwardReads(h.handleCatalogNode))
+    mux.HandleFunc("/v1/health/check/", h.forwardReads(h.handleHealthCheck))
+    mux.HandleFunc("/v1/health/checks/", h.forwardReads(h.handleHealthChecks))
+    mux.HandleFunc("/v1/health/state/", h.forwardReads(h.handleHealthState))
+    mux.HandleFunc("/v1/health/flapping", h.forwardReads(h.handleFlappingChecks))
+    mux.HandleFunc("/v1/health/service/", h.forwardReads(h.handleHealthService))
+    mux.HandleFunc("/v1/stream/health/service/", noWriteDeadline(h.forwardReads(h.handleStreamHealthService)))
+    mux.HandleFunc("/v1/namespaces", h.forwardReads(h.handleListNamespaces))
+    mux.HandleFunc("/v1/namespace/", h.forwardByMethod(h.handleNamespace)) // GET 读；PUT/DELETE 写
+    mux.HandleFunc("/v1/config/namespaces", h.forwardByMethod(h.handleNamespaceConfig)) // GET 读；PUT 写
+    mux.HandleFunc("/v1/kv/", h.forwardByMethod(h.handleKV)) // GET 读；PUT/DELETE 写
+    mux.HandleFunc("/v1/session/create", h.forwardWrites(h.handleSessionCreate))
+    mux.HandleFunc("/v1/session/destroy/", h.forwardWrites(h.handleSessionDestroy))
+    mux.HandleFunc("/v1/session/renew/", h.forwardWrites(h.handleSessionRenew))
+    mux.HandleFunc("/v1/session/info/", h.forwardReads(h.handleSessionInfo))
+    mux.HandleFunc("/v1/session/list", h.forwardReads(h.handleSessionList))
+    mux.HandleFunc("/v1/queries", h.forwardReads(h.handleListQueries))
+    mux.HandleFunc("/v1/query/", h.forwardByMethod(h.handleQuery)) // GET 读（含 execute）；PUT/DELETE 写
+    mux.HandleFunc("/v1/config/service-defaults", h.forwardReads(h.handleListServiceDefaults))
+    mux.HandleFunc("/v1/config/service-defaults/", h.forwardByMethod(h.handleServiceDefaults)) // GET 读；PUT/DELETE 写
+    mux.HandleFunc("/v1/config/service-splitter", h.forwardReads(h.handleListServiceSplitters))
+    mux.HandleFunc("/v1/config/service-splitter/", h.forwardByMethod(h.handleServiceSplitter)) // GET 读；PUT/DELETE 写
+    mux.HandleFunc("/v1/resolve/", h.forwardReads(h.handleResolve))
+    mux.HandleFunc("/v1/intentions", h.forwardByMethod(h.handleIntentions)) // GET 列出；POST 创建
+    mux.HandleFunc("/v1/intentions/check", h.forwardReads(h.handleIntentionCheck))
+    mux.HandleFunc("/v1/intention/", h.forwardByMethod(h.handleIntention)) // GET 读；PUT/DELETE 写
+    mux.HandleFunc("/v1/config/intentions", h.forwardByMethod(h.handleIntentionConfig)) // GET 读；PUT 写
+    mux.HandleFunc("/v1/raft/join", h.forwardWrites(h.handleRaftJoin)) // 经 forwardWrites 仅由 Leader 处理
 
     h.srv = &http.Server{
         Addr:         h.Addr,
         Handler:      logRequests(mux),
         ReadTimeout:  10 * time.Second,
-        WriteTimeout: 15 * time.Second,
+        WriteTimeout: serverWriteTimeout,
         IdleTimeout:  60 * time.Second,
     }
 
@@ -65,6 +132,89 @@ func logRequests(next http.Handler) http.Handler {
     })
 }
 
+// forwardWrites 在本节点不是 Leader 时，将请求原样代理到 Leader 的 HTTP 地址。
+func (h *HTTPServer) forwardWrites(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if h.Leader == nil {
+            next(w, r)
+            return
+        }
+        addr, isSelf := h.Leader.LeaderHTTPAddr()
+        if isSelf {
+            next(w, r)
+            return
+        }
+        h.proxyToLeader(w, r, addr)
+    }
+}
+
+// forwardReads 按读一致性模式处理读请求：
+// - ?stale：任意节点直接读本地内存；
+// - 默认：由 Leader 读取，Follower 转发给 Leader；
+// - ?consistent：同默认，且 Leader 在读取前经多数派确认领导权。
+// 所有本地处理的响应都会带上 X-Known-Leader 与 X-Last-Contact 头。
+func (h *HTTPServer) forwardReads(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        q := r.URL.Query()
+        _, stale := q["stale"]
+        _, consistent := q["consistent"]
+        if stale && consistent {
+            http.Error(w, "stale and consistent are mutually exclusive", http.StatusBadRequest)
+            return
+        }
+        if !stale && h.Leader != nil {
+            addr, isSelf := h.Leader.LeaderHTTPAddr()
+            if !isSelf {
+                h.proxyToLeader(w, r, addr)
+                return
+            }
+            if consistent && h.Consistency != nil {
+                if err := h.Consistency.VerifyLeader(); err != nil {
+                    http.Error(w, "verify leader failed: "+err.Error(), http.StatusServiceUnavailable)
+                    return
+                }
+            }
+        }
+        h.setConsistencyHeaders(w)
+        next(w, r)
+    }
+}
+
+// forwardByMethod 用于读写共用同一路径的接口：GET/HEAD 按读一致性处理，其余方法按写请求转发。
+func (h *HTTPServer) forwardByMethod(next http.HandlerFunc) http.HandlerFunc {
+    reads, writes := h.forwardReads(next), h.forwardWrites(next)
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method == http.MethodGet || r.Method == http.MethodHead {
+            reads(w, r)
+            return
+        }
+        writes(w, r)
+    }
+}
+
+// setConsistencyHeaders 写入 X-Known-Leader 与 X-Last-Contact（毫秒），供客户端判断数据新鲜度。
+func (h *HTTPServer) setConsistencyHeaders(w http.ResponseWriter) {
+    if h.Consistency == nil {
+        return
+    }
+    w.Header().Set("X-Known-Leader", strconv.FormatBool(h.Consistency.KnownLeader()))
+    w.Header().Set("X-Last-Contact", strconv.FormatInt(h.Consistency.LastContact().Milliseconds(), 10))
+}
+
+// proxyToLeader 将请求原样代理到 Leader；已转发过的请求不再二次转发。
+func (h *HTTPServer) proxyToLeader(w http.ResponseWriter, r *http.Request, addr string) {
+    if addr == "" || r.Header.Get(headerForwarded) != "" {
+        http.Error(w, "no known leader", http.StatusServiceUnavailable)
+        return
+    }
+    proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: addr})
+    proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+        http.Error(w, "forward to leader failed: "+err.Error(), http.StatusBadGateway)
+    }
+    r.Header.Set(headerForwarded, "1")
+    proxy.ServeHTTP(w, r)
+}
+
 func (h *HTTPServer) handleRegister(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPut && r.Method != http.MethodPost {
         http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -86,6 +236,8 @@ func (h *HTTPServer) handleRegister(w http.ResponseWriter, r *http.Request) {
         ID:        req.ID,
         Address:   req.Address,
         Port:      req.Port,
+        Node:      req.Node,
+        Zone:      req.Zone,
         Tags:      req.Tags,
         Meta:      req.Meta,
         Weights:   registry.Weights{Passing: req.Weights.Passing, Warning: req.Weights.Warning},
@@ -142,6 +294,75 @@ func (h *HTTPServer) handleDeregisterJSON(w http.ResponseWriter, r *http.Request
     w.WriteHeader(http.StatusOK)
 }
 
+func (h *HTTPServer) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPut && r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    // 路径: /v1/agent/service/maintenance/{id}?enable=true&reason=...&ns=...&service=...
+    // 省略 {id} 时作用于 ns/service 下的全部实例
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/agent/service/maintenance"), "/")
+    q := r.URL.Query()
+    enable, err := strconv.ParseBool(q.Get("enable"))
+    if err != nil {
+        http.Error(w, "missing or bad enable", http.StatusBadRequest)
+        return
+    }
+    idx, err := h.Reg.SetMaintenance(r.Context(), q.Get("ns"), q.Get("service"), id, enable, q.Get("reason"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.WriteHeader(http.StatusOK)
+}
+
+func (h *HTTPServer) handleRegisterNode(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPut && r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    var req RegisterNodeRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    specs, err := convertCheckDefs(req.Checks)
+    if err != nil {
+        http.Error(w, "bad checks: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    node := registry.Node{Name: req.Name, Address: req.Address, Meta: req.Meta}
+    idx, checkIDs, err := h.Reg.RegisterNode(r.Context(), node, specs)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(RegisterResponse{Index: idx, CheckIDs: checkIDs})
+}
+
+func (h *HTTPServer) handleDeregisterNode(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPut && r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    // 路径: /v1/agent/node/deregister/{name}
+    name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/agent/node/deregister/"), "/")
+    if name == "" {
+        http.Error(w, "missing node name", http.StatusBadRequest)
+        return
+    }
+    idx, err := h.Reg.DeregisterNode(r.Context(), name)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.WriteHeader(http.StatusOK)
+}
+
 func (h *HTTPServer) handleCheckPass(w http.ResponseWriter, r *http.Request) {
     h.handleCheckStatus(w, r, registry.StatusPassing)
 }
@@ -166,17 +387,28 @@ func (h *HTTPServer) handleCheckStatus(w http.ResponseWriter, r *http.Request, s
         return
     }
     checkID := path[idx+1:]
+    // 请求体为检查输出，?note= 为可选说明；二者在进入 Raft 日志前截断
+    max := h.CheckOutputMax
+    if max <= 0 {
+        max = DefaultCheckOutputMax
+    }
+    body, err := io.ReadAll(io.LimitReader(r.Body, int64(max)+1))
+    if err != nil {
+        http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    output := truncateOutput(string(body), max)
+    note := truncateOutput(r.URL.Query().Get("note"), max)
     var newIdx uint64
-    var err error
-    if st == registry.StatusPassing {
+    if st == registry.StatusPassing && output == "" && note == "" {
         // 若为 TTL 检查则续约；否则回退到 ReportCheck
         newIdx, err = h.Reg.RenewTTL(r.Context(), checkID)
         if err != nil {
             // not a TTL check, fallback to explicit report
-            newIdx, err = h.Reg.ReportCheck(r.Context(), checkID, st, "")
+            newIdx, err = h.Reg.ReportCheck(r.Context(), checkID, st, "", "")
         }
     } else {
-        newIdx, err = h.Reg.ReportCheck(r.Context(), checkID, st, "")
+        newIdx, err = h.Reg.ReportCheck(r.Context(), checkID, st, output, note)
     }
     if err != nil {
         http.Error(w, err.Error(), http.StatusBadRequest)
@@ -188,43 +420,87 @@ func (h *HTTPServer) handleCheckStatus(w http.ResponseWriter, r *http.Request, s
 
 func (h *HTTPServer) handleCatalogServices(w http.ResponseWriter, r *http.Request) {
     ns := r.URL.Query().Get("ns")
+    filter, err := parseFilterParam(r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    // 可选等待变更：命名空间下实例增删改时返回
+    if lastIdx, wait := parseBlockingParams(r); wait > 0 && lastIdx > 0 {
+        ctx, cancel := context.WithTimeout(r.Context(), wait)
+        defer cancel()
+        _, ch := h.Reg.WatchNamespace(ctx, ns, lastIdx)
+        select {
+        case <-ch:
+        case <-ctx.Done():
+        }
+    }
+
     names, idx, err := h.Reg.ListServices(r.Context(), ns)
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
+    // 带过滤表达式时，仅保留至少有一个实例满足表达式的服务
+    if filter != nil {
+        kept := names[:0]
+        for _, name := range names {
+            views, _, err := h.Reg.ListHealthyInstances(r.Context(), ns, name, registry.ListOptions{Filter: filter})
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusInternalServerError)
+                return
+            }
+            if len(views) > 0 {
+                kept = append(kept, name)
+            }
+        }
+        names = kept
+    }
     w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
     w.Header().Set("Content-Type", "application/json")
     _ = json.NewEncoder(w).Encode(names)
 }
 
+func (h *HTTPServer) handleCatalogNodes(w http.ResponseWriter, r *http.Request) {
+    nodes, idx, err := h.Reg.ListNodes(r.Context())
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(nodes)
+}
+
+func (h *HTTPServer) handleCatalogNode(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/catalog/node/{name}
+    name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/catalog/node/"), "/")
+    if name == "" {
+        http.Error(w, "missing node name", http.StatusBadRequest)
+        return
+    }
+    node, idx, err := h.Reg.GetNode(r.Context(), name)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(node)
+}
+
 func (h *HTTPServer) handleHealthService(w http.ResponseWriter, r *http.Request) {
     // 路径: /v1/health/service/{name}
     name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
     ns := r.URL.Query().Get("ns")
-    passing := r.URL.Query().Get("passing")
-    tag := r.URL.Query().Get("tag")
-    // zone is parsed but unused in M1
-    zone := r.URL.Query().Get("zone")
-
-    // 长轮询参数
-    lastIdxStr := r.URL.Query().Get("index")
-    waitStr := r.URL.Query().Get("wait")
-    var lastIdx uint64
-    if lastIdxStr != "" {
-        if v, err := strconv.ParseUint(lastIdxStr, 10, 64); err == nil {
-            lastIdx = v
-        }
-    }
-    var wait time.Duration
-    if waitStr != "" {
-        if d, err := time.ParseDuration(waitStr); err == nil {
-            wait = d
-        }
+    opts, err := parseListOptions(r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
     }
 
     // 可选等待变更
-    if wait > 0 && lastIdx > 0 {
+    if lastIdx, wait := parseBlockingParams(r); wait > 0 && lastIdx > 0 {
         ctx := r.Context()
         ctx, cancel := context.WithTimeout(ctx, wait)
         defer cancel()
@@ -238,34 +514,333 @@ func (h *HTTPServer) handleHealthService(w http.ResponseWriter, r *http.Request)
         }
     }
 
-    opts := registry.ListOptions{PassingOnly: passing == "1" || strings.ToLower(passing) == "true", Tag: tag, Zone: zone}
-    views, idx, err := h.Reg.ListHealthyInstances(r.Context(), ns, name, opts)
+    // ?include=checks：每个实例附带聚合状态与检查
+    include := parseInclude(r)
+    var views []registry.InstanceView
+    var health []registry.InstanceHealth
+    var idx uint64
+    if include["checks"] {
+        health, idx, err = h.Reg.ListInstanceHealth(r.Context(), ns, name, opts)
+    } else {
+        views, idx, err = h.Reg.ListHealthyInstances(r.Context(), ns, name, opts)
+    }
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
     w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
     w.Header().Set("Content-Type", "application/json")
+    // ?include=defaults：改为返回对象，附带服务级配置项
+    if include["defaults"] {
+        resp := ServiceHealthResponse{Instances: health}
+        if d, _, err := h.Reg.GetServiceDefaults(r.Context(), ns, name); err == nil {
+            resp.Defaults = &d
+        }
+        if !include["checks"] {
+            resp.Instances = make([]registry.InstanceHealth, 0, len(views))
+            for _, v := range views {
+                resp.Instances = append(resp.Instances, registry.InstanceHealth{InstanceView: v})
+            }
+        }
+        _ = json.NewEncoder(w).Encode(resp)
+        return
+    }
+    if include["checks"] {
+        _ = json.NewEncoder(w).Encode(health)
+        return
+    }
     _ = json.NewEncoder(w).Encode(views)
 }
 
+func (h *HTTPServer) handleHealthChecks(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/health/checks/{service}
+    name := strings.TrimPrefix(r.URL.Path, "/v1/health/checks/")
+    if name == "" || strings.Contains(name, "/") {
+        http.Error(w, "path must be /v1/health/checks/{service}", http.StatusBadRequest)
+        return
+    }
+    ns := r.URL.Query().Get("ns")
+
+    // 可选等待变更：检查状态变化会推进服务索引
+    if lastIdx, wait := parseBlockingParams(r); wait > 0 && lastIdx > 0 {
+        ctx, cancel := context.WithTimeout(r.Context(), wait)
+        defer cancel()
+        _, ch := h.Reg.WatchService(ctx, ns, name, lastIdx)
+        select {
+        case <-ch:
+        case <-ctx.Done():
+        }
+    }
+
+    checks, idx, err := h.Reg.ServiceChecks(r.Context(), ns, name)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(checks)
+}
+
+func (h *HTTPServer) handleHealthState(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/health/state/{passing|warning|critical|any}；?ns= 仅返回该命名空间下实例的检查
+    state := strings.TrimPrefix(r.URL.Path, "/v1/health/state/")
+    ns := r.URL.Query().Get("ns")
+    switch state {
+    case registry.CheckStateAny, registry.StatusPassing.String(), registry.StatusWarning.String(), registry.StatusCritical.String():
+    default:
+        http.Error(w, "state must be one of passing, warning, critical, any", http.StatusBadRequest)
+        return
+    }
+
+    // 可选等待变更：任意服务或节点检查变化时返回
+    if lastIdx, wait := parseBlockingParams(r); wait > 0 && lastIdx > 0 {
+        ctx, cancel := context.WithTimeout(r.Context(), wait)
+        defer cancel()
+        _, ch := h.Reg.WatchHealth(ctx, lastIdx)
+        select {
+        case <-ch:
+        case <-ctx.Done():
+        }
+    }
+
+    checks, idx, err := h.Reg.ChecksInState(r.Context(), ns, state)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(checks)
+}
+
+func (h *HTTPServer) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/health/check/{check_id} 或 /v1/health/check/{check_id}/history
+    rest := strings.TrimPrefix(r.URL.Path, "/v1/health/check/")
+    if checkID, ok := strings.CutSuffix(rest, "/history"); ok {
+        h.handleCheckHistory(w, r, checkID)
+        return
+    }
+    if rest == "" || strings.Contains(rest, "/") {
+        http.Error(w, "not found", http.StatusNotFound)
+        return
+    }
+    chk, idx, err := h.Reg.GetCheck(r.Context(), rest)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(chk)
+}
+
+func (h *HTTPServer) handleCheckHistory(w http.ResponseWriter, r *http.Request, checkID string) {
+    if checkID == "" || strings.Contains(checkID, "/") {
+        http.Error(w, "not found", http.StatusNotFound)
+        return
+    }
+    hist, idx, err := h.Reg.CheckHistory(r.Context(), checkID)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(hist)
+}
+
+func (h *HTTPServer) handleFlappingChecks(w http.ResponseWriter, r *http.Request) {
+    checks, idx, err := h.Reg.ListFlappingChecks(r.Context())
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(checks)
+}
+
+func (h *HTTPServer) handleListNamespaces(w http.ResponseWriter, r *http.Request) {
+    nss, idx, err := h.Reg.ListNamespaces(r.Context())
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(nss)
+}
+
+func (h *HTTPServer) handleNamespace(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/namespace/{name}
+    name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/namespace/"), "/")
+    if name == "" || strings.Contains(name, "/") {
+        http.Error(w, "missing or bad namespace name", http.StatusBadRequest)
+        return
+    }
+    var idx uint64
+    var err error
+    switch r.Method {
+    case http.MethodGet:
+        var ns registry.NamespaceView
+        ns, idx, err = h.Reg.GetNamespace(r.Context(), name)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(ns)
+        return
+    case http.MethodPut, http.MethodPost:
+        var req NamespaceRequest
+        if r.ContentLength != 0 {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+                return
+            }
+        }
+        ns := registry.Namespace{
+            Name:        name,
+            Description: req.Description,
+            Quota:       registry.NamespaceQuota{MaxInstances: req.Quota.MaxInstances, MaxServices: req.Quota.MaxServices},
+        }
+        idx, err = h.Reg.UpsertNamespace(r.Context(), ns)
+    case http.MethodDelete:
+        idx, err = h.Reg.DeleteNamespace(r.Context(), name)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.WriteHeader(http.StatusOK)
+}
+
+func (h *HTTPServer) handleNamespaceConfig(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        c, idx, err := h.Reg.GetNamespaceConfig(r.Context())
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(c)
+    case http.MethodPut, http.MethodPost:
+        var req NamespaceConfigRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+        idx, err := h.Reg.SetNamespaceConfig(r.Context(), registry.NamespaceConfig{Strict: req.Strict})
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.WriteHeader(http.StatusOK)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// parseListOptions 解析健康查询的公共参数：passing、tag、zone、near、filter、order。
+func parseListOptions(r *http.Request) (registry.ListOptions, error) {
+    q := r.URL.Query()
+    passing := q.Get("passing")
+    opts := registry.ListOptions{
+        PassingOnly: passing == "1" || strings.ToLower(passing) == "true",
+        Tag:         q.Get("tag"),
+        Zone:        q.Get("zone"),
+        Near:        q.Get("near"),
+        Order:       q.Get("order"),
+    }
+    filter, err := parseFilterParam(r)
+    if err != nil {
+        return opts, err
+    }
+    opts.Filter = filter
+    if opts.Order != "" && opts.Order != registry.OrderWeighted {
+        return opts, fmt.Errorf("invalid order: %s", opts.Order)
+    }
+    return opts, nil
+}
+
+// truncatedSuffix 标记被截断的检查输出。
+const truncatedSuffix = "...(truncated)"
+
+// truncateOutput 将 s 截断到最多 max 字节（按 UTF-8 字符边界），截断时以 truncatedSuffix 结尾。
+func truncateOutput(s string, max int) string {
+    if len(s) <= max {
+        return s
+    }
+    n, suffix := max-len(truncatedSuffix), truncatedSuffix
+    if n <= 0 {
+        n, suffix = max, ""
+    }
+    for n > 0 && !utf8.RuneStart(s[n]) {
+        n--
+    }
+    return s[:n] + suffix
+}
+
+// parseBlockingParams 解析长轮询参数 ?index=&wait=；非法值视为未提供，wait 不超过 maxBlockingWait。
+func parseBlockingParams(r *http.Request) (lastIdx uint64, wait time.Duration) {
+    q := r.URL.Query()
+    if v, err := strconv.ParseUint(q.Get("index"), 10, 64); err == nil {
+        lastIdx = v
+    }
+    if d, err := time.ParseDuration(q.Get("wait")); err == nil {
+        wait = min(d, maxBlockingWait)
+    }
+    return lastIdx, wait
+}
+
+// parseInclude 解析 ?include= 逗号分隔的附加内容列表。
+func parseInclude(r *http.Request) map[string]bool {
+    out := make(map[string]bool)
+    for _, v := range r.URL.Query()["include"] {
+        for _, item := range strings.Split(v, ",") {
+            if item = strings.TrimSpace(item); item != "" {
+                out[item] = true
+            }
+        }
+    }
+    return out
+}
+
+// parseFilterParam 解析 ?filter= 表达式；未提供时返回 nil。
+func parseFilterParam(r *http.Request) (*registry.Filter, error) {
+    expr := r.URL.Query().Get("filter")
+    if strings.TrimSpace(expr) == "" {
+        return nil, nil
+    }
+    f, err := registry.ParseFilter(expr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid filter: %w", err)
+    }
+    return f, nil
+}
+
 // --- 集群管理：加入 ---
-type Joiner interface { Join(nodeID, addr string) error }
+type Joiner interface { Join(nodeID, addr, httpAddr string) error }
 
 func (h *HTTPServer) handleRaftJoin(w http.ResponseWriter, r *http.Request) {
     if h.Joiner == nil { http.Error(w, "raft not enabled", http.StatusNotImplemented); return }
     if r.Method != http.MethodPost { http.Error(w, "method not allowed", http.StatusMethodNotAllowed); return }
-    if h.IsLeader != nil && !h.IsLeader() {
-        http.Error(w, "not leader", http.StatusBadRequest)
-        return
-    }
-    var req struct{ ID, Addr string }
+    var req struct{ ID, Addr, HTTPAddr string }
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, "bad request", http.StatusBadRequest)
         return
     }
     if req.ID == "" || req.Addr == "" { http.Error(w, "missing id/addr", http.StatusBadRequest); return }
-    if err := h.Joiner.Join(req.ID, req.Addr); err != nil {
+    if err := h.Joiner.Join(req.ID, req.Addr, req.HTTPAddr); err != nil {
         http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
@@ -275,7 +850,12 @@ func (h *HTTPServer) handleRaftJoin(w http.ResponseWriter, r *http.Request) {
 func convertCheckDefs(defs []CheckDef) ([]registry.CheckSpec, error) {
     out := make([]registry.CheckSpec, 0, len(defs))
     for _, d := range defs {
-        cs := registry.CheckSpec{Type: registry.CheckType(strings.ToLower(d.Type)), TTLRaw: d.TTL, HTTP: d.Path, IntRaw: d.Interval, TmRaw: d.Timeout}
+        cs := registry.CheckSpec{Type: registry.CheckType(strings.ToLower(d.Type)), TTLRaw: d.TTL, HTTP: d.Path, IntRaw: d.Interval, TmRaw: d.Timeout, DCARaw: d.DeregisterCriticalAfter}
+        if d.SuccessBeforePassing < 0 || d.FailuresBeforeCritical < 0 {
+            return nil, fmt.Errorf("bad thresholds: SuccessBeforePassing/FailuresBeforeCritical must be >= 0")
+        }
+        cs.SuccessBeforePassing = d.SuccessBeforePassing
+        cs.FailuresBeforeCritical = d.FailuresBeforeCritical
         if d.TTL != "" {
             dur, err := time.ParseDuration(d.TTL)
             if err != nil {
@@ -297,6 +877,13 @@ func convertCheckDefs(defs []CheckDef) ([]registry.CheckSpec, error) {
             }
             cs.Timeout = dur
         }
+        if d.DeregisterCriticalAfter != "" {
+            dur, err := time.ParseDuration(d.DeregisterCriticalAfter)
+            if err != nil {
+                return nil, fmt.Errorf("bad DeregisterCriticalAfter: %w", err)
+            }
+            cs.DeregisterCriticalAfter = dur
+        }
         out = append(out, cs)
     }
     return out, nil
@@ -0,0 +1,147 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "sider/internal/registry"
+)
+
+// intention.go - 服务间调用规则接口：
+// /v1/intentions（GET 列出，POST 创建）、/v1/intention/{id}（GET/PUT/DELETE）、
+// /v1/intentions/check?source=&destination=（评估，服务以 ns/name 或 name 表示）、
+// /v1/config/intentions（GET/PUT 默认策略）
+
+func (h *HTTPServer) handleIntentions(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        list, idx, err := h.Reg.ListIntentions(r.Context())
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(list)
+    case http.MethodPost, http.MethodPut:
+        h.upsertIntention(w, r, "")
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+func (h *HTTPServer) handleIntention(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/intention/{id}
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/intention/"), "/")
+    if id == "" || strings.Contains(id, "/") {
+        http.Error(w, "missing or bad intention id", http.StatusBadRequest)
+        return
+    }
+    switch r.Method {
+    case http.MethodGet:
+        ix, idx, err := h.Reg.GetIntention(r.Context(), id)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(ix)
+    case http.MethodPut, http.MethodPost:
+        // 仅更新已存在的规则，避免客户端拼错 ID 时意外创建
+        if _, _, err := h.Reg.GetIntention(r.Context(), id); err != nil {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        }
+        h.upsertIntention(w, r, id)
+    case http.MethodDelete:
+        idx, err := h.Reg.DeleteIntention(r.Context(), id)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.WriteHeader(http.StatusOK)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// upsertIntention 解析请求体并创建（id 为空）或更新规则，响应 {"ID": ...}。
+func (h *HTTPServer) upsertIntention(w http.ResponseWriter, r *http.Request, id string) {
+    var req IntentionRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    ix := registry.Intention{
+        ID:              id,
+        SourceNS:        req.SourceNS,
+        SourceName:      req.SourceName,
+        DestinationNS:   req.DestinationNS,
+        DestinationName: req.DestinationName,
+        Action:          registry.IntentionAction(strings.ToLower(req.Action)),
+        Description:     req.Description,
+    }
+    id, idx, err := h.Reg.UpsertIntention(r.Context(), ix)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(map[string]string{"ID": id})
+}
+
+func (h *HTTPServer) handleIntentionCheck(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    srcNS, src := splitServiceRef(q.Get("source"))
+    dstNS, dst := splitServiceRef(q.Get("destination"))
+    res, idx, err := h.Reg.CheckIntention(r.Context(), srcNS, src, dstNS, dst)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(res)
+}
+
+func (h *HTTPServer) handleIntentionConfig(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        c, idx, err := h.Reg.GetIntentionConfig(r.Context())
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(c)
+    case http.MethodPut, http.MethodPost:
+        var req IntentionConfigRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+        idx, err := h.Reg.SetIntentionConfig(r.Context(), registry.IntentionConfig{DefaultDeny: req.DefaultDeny})
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.WriteHeader(http.StatusOK)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// splitServiceRef 将 "ns/name" 拆分为命名空间与服务名；不含 "/" 时命名空间为空（即 default）。
+func splitServiceRef(ref string) (ns, name string) {
+    if ns, name, ok := strings.Cut(ref, "/"); ok {
+        return ns, name
+    }
+    return "", ref
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "sider/internal/registry"
+)
+
+// kv.go - 键值存储 HTTP 接口：/v1/kv/{key}
+// GET 支持 ?recurse（前缀列出）、?keys（仅列出键）、?raw（返回原始值）与 ?index=&wait= 阻塞查询；
+// PUT 请求体即值，支持 ?flags=、?cas= 以及 ?acquire={session}（加锁）/?release={session}（释放锁）；
+// DELETE 支持 ?recurse 与 ?cas=。
+
+func (h *HTTPServer) handleKV(w http.ResponseWriter, r *http.Request) {
+    key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+    switch r.Method {
+    case http.MethodGet:
+        h.handleKVGet(w, r, key)
+    case http.MethodPut, http.MethodPost:
+        h.handleKVPut(w, r, key)
+    case http.MethodDelete:
+        h.handleKVDelete(w, r, key)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+func (h *HTTPServer) handleKVGet(w http.ResponseWriter, r *http.Request, key string) {
+    q := r.URL.Query()
+    _, recurse := q["recurse"]
+    _, keysOnly := q["keys"]
+    _, raw := q["raw"]
+
+    // 可选等待变更：键（或前缀）被修改或删除时返回
+    if lastIdx, wait := parseBlockingParams(r); wait > 0 && lastIdx > 0 {
+        ctx, cancel := context.WithTimeout(r.Context(), wait)
+        defer cancel()
+        _, ch := h.Reg.KVWatch(ctx, key, recurse || keysOnly, lastIdx)
+        select {
+        case <-ch:
+        case <-ctx.Done():
+        }
+    }
+
+    if recurse || keysOnly {
+        entries, idx, err := h.Reg.KVList(r.Context(), key)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        if len(entries) == 0 {
+            http.Error(w, "not found", http.StatusNotFound)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        if keysOnly {
+            keys := make([]string, 0, len(entries))
+            for _, e := range entries {
+                keys = append(keys, e.Key)
+            }
+            _ = json.NewEncoder(w).Encode(keys)
+            return
+        }
+        _ = json.NewEncoder(w).Encode(entries)
+        return
+    }
+
+    if key == "" {
+        http.Error(w, "missing key", http.StatusBadRequest)
+        return
+    }
+    entry, idx, err := h.Reg.KVGet(r.Context(), key)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    if entry == nil {
+        http.Error(w, "not found", http.StatusNotFound)
+        return
+    }
+    if raw {
+        w.Header().Set("Content-Type", "application/octet-stream")
+        _, _ = w.Write(entry.Value)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(entry)
+}
+
+func (h *HTTPServer) handleKVPut(w http.ResponseWriter, r *http.Request, key string) {
+    if key == "" {
+        http.Error(w, "missing key", http.StatusBadRequest)
+        return
+    }
+    q := r.URL.Query()
+    op := registry.KVOp{Verb: registry.KVSet, Key: key}
+    if v := q.Get("flags"); v != "" {
+        flags, err := strconv.ParseUint(v, 10, 64)
+        if err != nil {
+            http.Error(w, "bad flags: "+v, http.StatusBadRequest)
+            return
+        }
+        op.Flags = flags
+    }
+    if v := q.Get("cas"); v != "" {
+        cas, err := strconv.ParseUint(v, 10, 64)
+        if err != nil {
+            http.Error(w, "bad cas: "+v, http.StatusBadRequest)
+            return
+        }
+        op.Verb, op.Index = registry.KVCAS, cas
+    }
+    if v := q.Get("acquire"); v != "" {
+        op.Verb, op.Session = registry.KVLock, v
+    }
+    if v := q.Get("release"); v != "" {
+        op.Verb, op.Session = registry.KVUnlock, v
+    }
+    if op.Session != "" && (q.Get("cas") != "" || (q.Get("acquire") != "" && q.Get("release") != "")) {
+        http.Error(w, "acquire, release and cas are mutually exclusive", http.StatusBadRequest)
+        return
+    }
+    body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, registry.MaxKVValueSize))
+    if err != nil {
+        http.Error(w, "bad body: "+err.Error(), http.StatusRequestEntityTooLarge)
+        return
+    }
+    op.Value = body
+    h.writeKVResult(w, r, op)
+}
+
+func (h *HTTPServer) handleKVDelete(w http.ResponseWriter, r *http.Request, key string) {
+    q := r.URL.Query()
+    op := registry.KVOp{Verb: registry.KVDelete, Key: key}
+    if _, recurse := q["recurse"]; recurse {
+        op.Verb = registry.KVDeleteTree
+    } else if key == "" {
+        http.Error(w, "missing key", http.StatusBadRequest)
+        return
+    }
+    if v := q.Get("cas"); v != "" {
+        cas, err := strconv.ParseUint(v, 10, 64)
+        if err != nil || op.Verb == registry.KVDeleteTree {
+            http.Error(w, "bad cas: "+v, http.StatusBadRequest)
+            return
+        }
+        op.Verb, op.Index = registry.KVDeleteCAS, cas
+    }
+    h.writeKVResult(w, r, op)
+}
+
+// writeKVResult 执行写操作并以 true/false 响应（false 表示 CAS 或加锁条件不满足）。
+func (h *HTTPServer) writeKVResult(w http.ResponseWriter, r *http.Request, op registry.KVOp) {
+    ok, idx, err := h.Reg.KVApply(r.Context(), op)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(ok)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "sider/internal/registry"
+)
+
+// query.go - 预设查询接口：/v1/queries、/v1/query/{name}、/v1/query/{name}/execute
+
+func (h *HTTPServer) handleListQueries(w http.ResponseWriter, r *http.Request) {
+    queries, idx, err := h.Reg.ListPreparedQueries(r.Context())
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(queries)
+}
+
+func (h *HTTPServer) handleQuery(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/query/{name} 或 /v1/query/{name}/execute
+    rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/query/"), "/")
+    if name, ok := strings.CutSuffix(rest, "/execute"); ok {
+        h.handleExecuteQuery(w, r, name)
+        return
+    }
+    name := rest
+    if name == "" || strings.Contains(name, "/") {
+        http.Error(w, "missing or bad query name", http.StatusBadRequest)
+        return
+    }
+    var idx uint64
+    var err error
+    switch r.Method {
+    case http.MethodGet:
+        var q registry.PreparedQuery
+        q, idx, err = h.Reg.GetPreparedQuery(r.Context(), name)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(q)
+        return
+    case http.MethodPut, http.MethodPost:
+        var req PreparedQueryRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+        q := registry.PreparedQuery{
+            Name:        name,
+            Namespace:   req.Namespace,
+            Service:     req.Service,
+            Tag:         req.Tag,
+            Filter:      req.Filter,
+            OnlyPassing: req.OnlyPassing,
+            Failover:    req.Failover,
+        }
+        idx, err = h.Reg.UpsertPreparedQuery(r.Context(), q)
+    case http.MethodDelete:
+        idx, err = h.Reg.DeletePreparedQuery(r.Context(), name)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.WriteHeader(http.StatusOK)
+}
+
+// handleExecuteQuery 执行预设查询；响应体的 Namespace 为实际应答的命名空间，
+// 同时写入 X-Query-Namespace 与 X-Query-Failovers 头，便于只关心实例列表的客户端观察回退。
+func (h *HTTPServer) handleExecuteQuery(w http.ResponseWriter, r *http.Request, name string) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if name == "" || strings.Contains(name, "/") {
+        http.Error(w, "missing or bad query name", http.StatusBadRequest)
+        return
+    }
+    res, idx, err := h.Reg.ExecutePreparedQuery(r.Context(), name)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("X-Query-Namespace", res.Namespace)
+    w.Header().Set("X-Query-Failovers", fmt.Sprintf("%d", res.Failovers))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "sider/internal/registry"
+)
+
+// session.go - 会话接口：/v1/session/{create,destroy,renew,info,list}
+// 会话配合 /v1/kv/{key}?acquire= / ?release= 实现分布式锁与选主。
+
+func (h *HTTPServer) handleSessionCreate(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPut && r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    var req SessionRequest
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+    }
+    s := registry.Session{
+        Name:         req.Name,
+        Checks:       req.Checks,
+        TTLRaw:       req.TTL,
+        LockDelayRaw: req.LockDelay,
+        Behavior:     registry.SessionBehavior(req.Behavior),
+    }
+    s, idx, err := h.Reg.CreateSession(r.Context(), s)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(s)
+}
+
+func (h *HTTPServer) handleSessionDestroy(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPut && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    // 路径: /v1/session/destroy/{id}
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/session/destroy/"), "/")
+    if id == "" {
+        http.Error(w, "missing session id", http.StatusBadRequest)
+        return
+    }
+    idx, err := h.Reg.DestroySession(r.Context(), id)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.WriteHeader(http.StatusOK)
+}
+
+func (h *HTTPServer) handleSessionRenew(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPut && r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    // 路径: /v1/session/renew/{id}；会话已失效时返回 404，客户端应重新创建会话并重新加锁
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/session/renew/"), "/")
+    if id == "" {
+        http.Error(w, "missing session id", http.StatusBadRequest)
+        return
+    }
+    s, idx, err := h.Reg.RenewSession(r.Context(), id)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(s)
+}
+
+func (h *HTTPServer) handleSessionInfo(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/session/info/{id}
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/session/info/"), "/")
+    if id == "" {
+        http.Error(w, "missing session id", http.StatusBadRequest)
+        return
+    }
+    s, idx, err := h.Reg.GetSession(r.Context(), id)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(s)
+}
+
+func (h *HTTPServer) handleSessionList(w http.ResponseWriter, r *http.Request) {
+    sessions, idx, err := h.Reg.ListSessions(r.Context())
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(sessions)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "sider/internal/registry"
+)
+
+// splitter.go - 子集定义与流量拆分接口：/v1/config/service-splitter[/{ns}/{name}]，
+// 以及按拆分配置解析服务实例的 /v1/resolve/{name}
+
+func (h *HTTPServer) handleListServiceSplitters(w http.ResponseWriter, r *http.Request) {
+    list, idx, err := h.Reg.ListServiceSplitters(r.Context(), r.URL.Query().Get("ns"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(list)
+}
+
+func (h *HTTPServer) handleServiceSplitter(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/config/service-splitter/{ns}/{name}
+    rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/config/service-splitter/"), "/")
+    ns, name, ok := strings.Cut(rest, "/")
+    if !ok || ns == "" || name == "" || strings.Contains(name, "/") {
+        http.Error(w, "path must be /v1/config/service-splitter/{ns}/{name}", http.StatusBadRequest)
+        return
+    }
+    var idx uint64
+    var err error
+    switch r.Method {
+    case http.MethodGet:
+        var s registry.ServiceSplitter
+        s, idx, err = h.Reg.GetServiceSplitter(r.Context(), ns, name)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        }
+        w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(s)
+        return
+    case http.MethodPut, http.MethodPost:
+        var req ServiceSplitterRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+        s := registry.ServiceSplitter{
+            Namespace: ns,
+            Name:      name,
+            Subsets:   req.Subsets,
+            Splits:    req.Splits,
+        }
+        idx, err = h.Reg.UpsertServiceSplitter(r.Context(), s)
+    case http.MethodDelete:
+        idx, err = h.Reg.DeleteServiceSplitter(r.Context(), ns, name)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.WriteHeader(http.StatusOK)
+}
+
+func (h *HTTPServer) handleResolve(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/resolve/{name}；支持与健康查询相同的 passing/tag/zone/filter 等参数及长轮询
+    name := strings.TrimPrefix(r.URL.Path, "/v1/resolve/")
+    if name == "" || strings.Contains(name, "/") {
+        http.Error(w, "path must be /v1/resolve/{name}", http.StatusBadRequest)
+        return
+    }
+    ns := r.URL.Query().Get("ns")
+    opts, err := parseListOptions(r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    // 拆分配置变更同样推进服务索引，可按服务等待
+    if lastIdx, wait := parseBlockingParams(r); wait > 0 && lastIdx > 0 {
+        ctx, cancel := context.WithTimeout(r.Context(), wait)
+        defer cancel()
+        _, ch := h.Reg.WatchService(ctx, ns, name, lastIdx)
+        select {
+        case <-ch:
+        case <-ctx.Done():
+        }
+    }
+
+    res, idx, err := h.Reg.ResolveService(r.Context(), ns, name, opts)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("X-Index", fmt.Sprintf("%d", idx))
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(res)
+}
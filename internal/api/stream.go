@@ -0,0 +1,182 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "sider/internal/registry"
+)
+
+// stream.go - 基于 SSE（Server-Sent Events）的健康实例变更推送
+// 服务索引推进且（按查询条件过滤后的）实例集合有变化时推送一次事件，事件 id 即服务索引；
+// 客户端断线重连时通过 Last-Event-ID 续传。
+
+const defaultStreamHeartbeat = 15 * time.Second
+
+// streamSnapshot 为 full 事件的数据：完整实例集合。
+type streamSnapshot struct {
+    Index     uint64                  `json:"Index"`
+    Instances []registry.InstanceView `json:"Instances"`
+}
+
+// streamDelta 为 delta 事件的数据：相对上一事件新增/变化的实例与被移除的实例 ID。
+type streamDelta struct {
+    Index   uint64                  `json:"Index"`
+    Upserts []registry.InstanceView `json:"Upserts"`
+    Removes []string                `json:"Removes"`
+}
+
+// noWriteDeadline 取消服务端 WriteTimeout，供长连接使用（须在转发之前，代理本身也是长连接）。
+func noWriteDeadline(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        _ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+        next(w, r)
+    }
+}
+
+func (h *HTTPServer) handleStreamHealthService(w http.ResponseWriter, r *http.Request) {
+    // 路径: /v1/stream/health/service/{name}?ns=&mode=full|delta&heartbeat=15s&passing/tag/zone/near/filter
+    name := strings.TrimPrefix(r.URL.Path, "/v1/stream/health/service/")
+    if name == "" {
+        http.Error(w, "missing service name", http.StatusBadRequest)
+        return
+    }
+    q := r.URL.Query()
+    ns := q.Get("ns")
+    opts, err := parseListOptions(r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    // 加权随机排序每次结果不同，对变更流没有意义
+    opts.Order = ""
+    mode := q.Get("mode")
+    if mode == "" {
+        mode = "full"
+    }
+    if mode != "full" && mode != "delta" {
+        http.Error(w, "invalid mode: "+mode, http.StatusBadRequest)
+        return
+    }
+    heartbeat := defaultStreamHeartbeat
+    if v := q.Get("heartbeat"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil || d <= 0 {
+            http.Error(w, "invalid heartbeat: "+v, http.StatusBadRequest)
+            return
+        }
+        heartbeat = d
+    }
+    // 续传位置：Last-Event-ID（浏览器自动携带）优先，其次 ?index=
+    var last uint64
+    resume := false
+    for _, v := range []string{r.Header.Get("Last-Event-ID"), q.Get("index")} {
+        if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+            last, resume = n, true
+            break
+        }
+    }
+
+    rc := http.NewResponseController(w)
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("X-Accel-Buffering", "no") // 关闭反向代理缓冲
+    w.WriteHeader(http.StatusOK)
+    if err := rc.Flush(); err != nil {
+        return
+    }
+
+    ctx := r.Context()
+    ticker := time.NewTicker(heartbeat)
+    defer ticker.Stop()
+
+    // prev 为上一次推送给客户端的实例集合（delta 模式使用）；续传时客户端状态未知，首个事件总是 full
+    // sent 为上一次 full 事件的实例 JSON，索引推进但结果不变（如被过滤掉的实例变化）时不重复推送
+    var prev map[string]registry.InstanceView
+    var sent string
+    first := true
+    for {
+        views, idx, err := h.Reg.ListHealthyInstances(ctx, ns, name, opts)
+        if err != nil {
+            writeSSE(w, "error", idx, map[string]string{"Error": err.Error()})
+            _ = rc.Flush()
+            return
+        }
+        if (first && !resume) || idx > last {
+            cur := make(map[string]registry.InstanceView, len(views))
+            for _, v := range views {
+                cur[v.ID] = v
+            }
+            if mode == "full" || prev == nil {
+                data, _ := json.Marshal(views)
+                if prev == nil || string(data) != sent {
+                    writeSSE(w, "full", idx, streamSnapshot{Index: idx, Instances: views})
+                    sent = string(data)
+                }
+            } else if d := diffInstances(prev, cur, views); len(d.Upserts) > 0 || len(d.Removes) > 0 {
+                d.Index = idx
+                writeSSE(w, "delta", idx, d)
+            }
+            if err := rc.Flush(); err != nil {
+                return
+            }
+            prev, last = cur, idx
+        }
+        first = false
+
+        _, ch := h.Reg.WatchService(ctx, ns, name, last)
+    wait:
+        for {
+            select {
+            case <-ch:
+                break wait
+            case <-ticker.C:
+                // 注释行作为心跳，保持经过代理的连接不被回收
+                if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+                    return
+                }
+                if err := rc.Flush(); err != nil {
+                    return
+                }
+            case <-ctx.Done():
+                return
+            }
+        }
+    }
+}
+
+// diffInstances 计算 prev -> cur 的变化；Upserts 保持 views 的顺序，Removes 按 ID 排序。
+func diffInstances(prev, cur map[string]registry.InstanceView, views []registry.InstanceView) streamDelta {
+    d := streamDelta{Upserts: []registry.InstanceView{}, Removes: []string{}}
+    for _, v := range views {
+        old, ok := prev[v.ID]
+        if !ok || !sameView(old, v) {
+            d.Upserts = append(d.Upserts, v)
+        }
+    }
+    for id := range prev {
+        if _, ok := cur[id]; !ok {
+            d.Removes = append(d.Removes, id)
+        }
+    }
+    sort.Strings(d.Removes)
+    return d
+}
+
+// sameView 以 JSON 编码比较两个实例视图是否相同。
+func sameView(a, b registry.InstanceView) bool {
+    ja, _ := json.Marshal(a)
+    jb, _ := json.Marshal(b)
+    return string(ja) == string(jb)
+}
+
+// writeSSE 写出一个 SSE 事件：id 为服务索引，data 为单行 JSON。
+func writeSSE(w http.ResponseWriter, event string, id uint64, v interface{}) {
+    data, _ := json.Marshal(v)
+    fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+}
@@ -1,5 +1,7 @@
 package api
 
+import "sider/internal/registry"
+
 // HTTP API 的请求/响应结构体。
 
 type RegisterServiceRequest struct {
@@ -8,6 +10,8 @@ type RegisterServiceRequest struct {
     ID        string            `json:"ID"`
     Address   string            `json:"Address"`
     Port      int               `json:"Port"`
+    Node      string            `json:"Node"` // 所属节点名；节点检查的状态会叠加到实例
+    Zone      string            `json:"Zone"` // 可用区；为空时取 Meta["zone"]
     Tags      []string          `json:"Tags"`
     Meta      map[string]string `json:"Meta"`
     Checks    []CheckDef        `json:"Checks"`
@@ -17,12 +21,22 @@ type RegisterServiceRequest struct {
     } `json:"Weights"`
 }
 
+type RegisterNodeRequest struct {
+    Name    string            `json:"Name"`
+    Address string            `json:"Address"`
+    Meta    map[string]string `json:"Meta"`
+    Checks  []CheckDef        `json:"Checks"` // 节点级检查
+}
+
 type CheckDef struct {
     Type     string `json:"Type"`     // 检查类型（ttl/http/tcp/cmd）
     TTL      string `json:"TTL"`      // 仅 ttl 检查使用
     Path     string `json:"Path"`     // http 检查使用
     Interval string `json:"Interval"` // 检查间隔
     Timeout  string `json:"Timeout"`  // 超时
+    DeregisterCriticalAfter string `json:"DeregisterCriticalAfter"` // 持续 critical 超过该时长后自动注销实例
+    SuccessBeforePassing    int    `json:"SuccessBeforePassing"`    // 连续成功 N 次后才转为 passing
+    FailuresBeforeCritical  int    `json:"FailuresBeforeCritical"`  // 连续失败 N 次后才转为 critical
 }
 
 type DeregisterRequest struct {
@@ -36,3 +50,67 @@ type RegisterResponse struct {
     InstanceID string   `json:"InstanceID"`
     CheckIDs   []string `json:"CheckIDs"`
 }
+
+type NamespaceRequest struct {
+    Description string `json:"Description"`
+    Quota       struct {
+        MaxInstances int `json:"MaxInstances"` // 0 表示不限制
+        MaxServices  int `json:"MaxServices"`  // 0 表示不限制
+    } `json:"Quota"`
+}
+
+type NamespaceConfigRequest struct {
+    Strict bool `json:"Strict"` // 拒绝注册到未显式创建的命名空间（default 除外）
+}
+
+type SessionRequest struct {
+    Name      string   `json:"Name"`
+    Checks    []string `json:"Checks"`    // 关联的检查 ID；任一变为 critical 或被删除时会话失效
+    TTL       string   `json:"TTL"`       // 为空表示不过期；否则须在 10s~24h 之间，需定期续约
+    LockDelay string   `json:"LockDelay"` // 会话失效后其释放的键不可被再次加锁的时长，默认 15s
+    Behavior  string   `json:"Behavior"`  // 失效时对持有的键：release（默认）或 delete
+}
+
+type PreparedQueryRequest struct {
+    Namespace   string   `json:"Namespace"` // 主命名空间，默认 default
+    Service     string   `json:"Service"`
+    Tag         string   `json:"Tag"`
+    Filter      string   `json:"Filter"` // 过滤表达式，语法同 ?filter=
+    OnlyPassing bool     `json:"OnlyPassing"`
+    Failover    []string `json:"Failover"` // 主命名空间无 passing 实例时依次尝试的命名空间
+}
+
+type ServiceDefaultsRequest struct {
+    Protocol string            `json:"Protocol"` // 合并为实例 Meta["protocol"]
+    Meta     map[string]string `json:"Meta"`     // 合并为实例 Meta 中缺失的键
+    Weights  struct {
+        Passing int `json:"Passing"`
+        Warning int `json:"Warning"`
+    } `json:"Weights"` // 实例权重为 0 时使用
+    TTL string `json:"TTL"` // TTL 检查未设置 TTL 时使用
+}
+
+// ServiceHealthResponse 为 /v1/health/service/{name}?include=defaults 的响应：实例列表附带服务级配置项。
+// 同时指定 include=checks 时，实例附带聚合状态与检查。
+type ServiceHealthResponse struct {
+    Defaults  *registry.ServiceDefaults `json:"Defaults"` // 未配置时为 null
+    Instances []registry.InstanceHealth `json:"Instances"`
+}
+
+type IntentionConfigRequest struct {
+    DefaultDeny bool `json:"DefaultDeny"` // 没有匹配的规则时拒绝调用
+}
+
+type IntentionRequest struct {
+    SourceNS        string `json:"SourceNS"` // 默认 default；"*" 表示任意命名空间（此时 SourceName 须为 "*"）
+    SourceName      string `json:"SourceName"`
+    DestinationNS   string `json:"DestinationNS"`
+    DestinationName string `json:"DestinationName"`
+    Action          string `json:"Action"` // allow 或 deny
+    Description     string `json:"Description"`
+}
+
+type ServiceSplitterRequest struct {
+    Subsets []registry.ServiceSubset `json:"Subsets"` // 子集：按 Tag 和/或 Filter 选取实例
+    Splits  []registry.ServiceSplit  `json:"Splits"`  // 流量拆分：Percent 之和须为 100
+}
@@ -0,0 +1,458 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filter.go - 查询过滤表达式
+// 支持对 InstanceView 求值的简单表达式语言，例如：
+//
+//	"v2" in Tags and Meta.version == "1.4" and Port != 0
+//
+// 语法：
+//
+//	expr       := and ("or" and)*
+//	and        := unary ("and" unary)*
+//	unary      := "not" unary | "(" expr ")" | comparison
+//	comparison := selector ("==" | "!=") value
+//	            | value ["not"] "in" selector
+//	            | selector ["not"] "contains" value
+//	            | selector "is" ["not"] "empty"
+//
+// 选择器：Namespace、Service、ID、Address、Port、Node、Zone、Tags、Meta、Meta.<key>、
+// Weights.Passing、Weights.Warning。值为双引号字符串或数字。
+
+// Filter 是已解析的过滤表达式，可并发使用。
+type Filter struct {
+	expr string
+	root filterNode
+}
+
+// ParseFilter 解析过滤表达式；语法错误或未知选择器时返回错误。
+func ParseFilter(expr string) (*Filter, error) {
+	toks, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// Match 判断实例是否满足表达式；nil Filter 匹配所有实例。
+func (f *Filter) Match(v InstanceView) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.eval(v)
+}
+
+// String 返回原始表达式
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// ============================================================================
+// 语法树
+// ============================================================================
+
+type filterNode interface {
+	eval(v InstanceView) bool
+}
+
+type andNode struct{ l, r filterNode }
+type orNode struct{ l, r filterNode }
+type notNode struct{ n filterNode }
+
+func (n andNode) eval(v InstanceView) bool { return n.l.eval(v) && n.r.eval(v) }
+func (n orNode) eval(v InstanceView) bool  { return n.l.eval(v) || n.r.eval(v) }
+func (n notNode) eval(v InstanceView) bool { return !n.n.eval(v) }
+
+// compareNode: selector == / != value
+type compareNode struct {
+	sel   string
+	value string
+	neq   bool
+}
+
+func (n compareNode) eval(v InstanceView) bool {
+	s, _, _ := selectField(v, n.sel)
+	return (s == n.value) != n.neq
+}
+
+// inNode: value in selector（等价于 selector contains value）
+type inNode struct {
+	sel   string
+	value string
+}
+
+func (n inNode) eval(v InstanceView) bool {
+	s, list, m := selectField(v, n.sel)
+	switch {
+	case list != nil:
+		return hasString(list, n.value)
+	case m != nil:
+		_, ok := m[n.value]
+		return ok
+	default:
+		return strings.Contains(s, n.value)
+	}
+}
+
+// emptyNode: selector is empty
+type emptyNode struct {
+	sel string
+}
+
+func (n emptyNode) eval(v InstanceView) bool {
+	s, list, m := selectField(v, n.sel)
+	switch {
+	case list != nil:
+		return len(list) == 0
+	case m != nil:
+		return len(m) == 0
+	default:
+		return s == ""
+	}
+}
+
+// selectField 按选择器取值：标量以字符串返回，Tags 返回列表，Meta 返回映射。
+// 为区分“空列表”与“标量”，列表/映射为空时返回非 nil 的空值。
+func selectField(v InstanceView, sel string) (string, []string, map[string]string) {
+	switch sel {
+	case "Namespace":
+		return v.Namespace, nil, nil
+	case "Service":
+		return v.Service, nil, nil
+	case "ID":
+		return v.ID, nil, nil
+	case "Address":
+		return v.Address, nil, nil
+	case "Port":
+		return strconv.Itoa(v.Port), nil, nil
+	case "Node":
+		return v.Node, nil, nil
+	case "Zone":
+		return v.Zone, nil, nil
+	case "Weights.Passing":
+		return strconv.Itoa(v.Weights.Passing), nil, nil
+	case "Weights.Warning":
+		return strconv.Itoa(v.Weights.Warning), nil, nil
+	case "Tags":
+		if v.Tags == nil {
+			return "", []string{}, nil
+		}
+		return "", v.Tags, nil
+	case "Meta":
+		if v.Meta == nil {
+			return "", nil, map[string]string{}
+		}
+		return "", nil, v.Meta
+	}
+	if key, ok := strings.CutPrefix(sel, "Meta."); ok {
+		return v.Meta[key], nil, nil
+	}
+	return "", nil, nil
+}
+
+// validSelector 判断选择器是否合法；collection 表示是否为 Tags/Meta 这类集合。
+func validSelector(sel string) (ok, collection bool) {
+	switch sel {
+	case "Namespace", "Service", "ID", "Address", "Port", "Node", "Zone", "Weights.Passing", "Weights.Warning":
+		return true, false
+	case "Tags", "Meta":
+		return true, true
+	}
+	if key, found := strings.CutPrefix(sel, "Meta."); found && key != "" {
+		return true, false
+	}
+	return false, false
+}
+
+// ============================================================================
+// 词法分析
+// ============================================================================
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+	tokEq
+	tokNeq
+)
+
+type filterToken struct {
+	kind tokKind
+	text string // 字符串字面量为去引号后的值
+	pos  int
+}
+
+func lexFilter(s string) ([]filterToken, error) {
+	var toks []filterToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, filterToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, filterToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == '=' || c == '!':
+			if i+1 >= len(s) || s[i+1] != '=' {
+				return nil, fmt.Errorf("unexpected %q at position %d", string(c), i)
+			}
+			kind := tokEq
+			if c == '!' {
+				kind = tokNeq
+			}
+			toks = append(toks, filterToken{kind: kind, text: s[i : i+2], pos: i})
+			i += 2
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			val, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("bad string at position %d: %v", i, err)
+			}
+			toks = append(toks, filterToken{kind: tokString, text: val, pos: i})
+			i = j + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			if _, err := strconv.Atoi(s[i:j]); err != nil {
+				return nil, fmt.Errorf("bad number at position %d", i)
+			}
+			toks = append(toks, filterToken{kind: tokNumber, text: s[i:j], pos: i})
+			i = j
+		case isIdentByte(c):
+			j := i
+			for j < len(s) && (isIdentByte(s[j]) || (s[j] >= '0' && s[j] <= '9') || s[j] == '.' || s[j] == '-') {
+				j++
+			}
+			toks = append(toks, filterToken{kind: tokIdent, text: s[i:j], pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", string(c), i)
+		}
+	}
+	toks = append(toks, filterToken{kind: tokEOF, text: "end of expression", pos: len(s)})
+	return toks, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// ============================================================================
+// 语法分析
+// ============================================================================
+
+type filterParser struct {
+	toks []filterToken
+	pos  int
+}
+
+func (p *filterParser) peek() filterToken { return p.toks[p.pos] }
+
+func (p *filterParser) next() filterToken {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword 判断下一个 token 是否为指定关键字（不区分大小写）
+func (p *filterParser) keyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *filterParser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		t := p.peek()
+		return fmt.Errorf("expected %q but found %q at position %d", kw, t.text, t.pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.keyword("not") {
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n: n}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, fmt.Errorf("expected \")\" but found %q at position %d", t.text, t.pos)
+		}
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	first := p.next()
+	switch first.kind {
+	case tokString, tokNumber:
+		// value [not] in selector
+		negate := false
+		if p.keyword("not") {
+			p.next()
+			negate = true
+		}
+		if err := p.expectKeyword("in"); err != nil {
+			return nil, err
+		}
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		return maybeNot(inNode{sel: sel, value: first.text}, negate), nil
+	case tokIdent:
+		sel := first.text
+		ok, collection := validSelector(sel)
+		if !ok {
+			return nil, fmt.Errorf("unknown selector %q at position %d", sel, first.pos)
+		}
+		t := p.peek()
+		switch {
+		case t.kind == tokEq || t.kind == tokNeq:
+			p.next()
+			if collection {
+				return nil, fmt.Errorf("selector %q does not support %s", sel, t.text)
+			}
+			val, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			return compareNode{sel: sel, value: val, neq: t.kind == tokNeq}, nil
+		case p.keyword("is"):
+			p.next()
+			negate := false
+			if p.keyword("not") {
+				p.next()
+				negate = true
+			}
+			if err := p.expectKeyword("empty"); err != nil {
+				return nil, err
+			}
+			return maybeNot(emptyNode{sel: sel}, negate), nil
+		case p.keyword("contains") || p.keyword("not"):
+			negate := false
+			if p.keyword("not") {
+				p.next()
+				negate = true
+			}
+			if err := p.expectKeyword("contains"); err != nil {
+				return nil, err
+			}
+			val, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			return maybeNot(inNode{sel: sel, value: val}, negate), nil
+		default:
+			return nil, fmt.Errorf("expected operator after %q but found %q at position %d", sel, t.text, t.pos)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", first.text, first.pos)
+	}
+}
+
+func (p *filterParser) parseSelector() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", fmt.Errorf("expected selector but found %q at position %d", t.text, t.pos)
+	}
+	if ok, _ := validSelector(t.text); !ok {
+		return "", fmt.Errorf("unknown selector %q at position %d", t.text, t.pos)
+	}
+	return t.text, nil
+}
+
+func (p *filterParser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind != tokString && t.kind != tokNumber {
+		return "", fmt.Errorf("expected value but found %q at position %d", t.text, t.pos)
+	}
+	return t.text, nil
+}
+
+func maybeNot(n filterNode, negate bool) filterNode {
+	if negate {
+		return notNode{n: n}
+	}
+	return n
+}
@@ -0,0 +1,120 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	v := InstanceView{
+		Namespace: DefaultNamespace,
+		Service:   "web",
+		ID:        "web-1",
+		Address:   "10.0.0.1",
+		Port:      8080,
+		Zone:      "z1",
+		Tags:      []string{"v2", "primary"},
+		Meta:      map[string]string{"version": "1.4", "env": "prod", "note": `say "hi"`},
+		Weights:   Weights{Passing: 10, Warning: 1},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// 比较与选择器
+		{`Service == "web"`, true},
+		{`Service != "web"`, false},
+		{`Port == 8080`, true},
+		{`Port == "8080"`, true},
+		{`Weights.Passing == 10`, true},
+		{`Meta.version == "1.4"`, true},
+		{`Meta.missing == ""`, true},
+		{`Node == ""`, true},
+
+		// in / contains / is empty
+		{`"v2" in Tags`, true},
+		{`"v3" in Tags`, false},
+		{`"v3" not in Tags`, true},
+		{`"env" in Meta`, true},
+		{`"10.0" in Address`, true},
+		{`Tags contains "primary"`, true},
+		{`Tags not contains "primary"`, false},
+		{`Meta is empty`, false},
+		{`Meta is not empty`, true},
+		{`Node is empty`, true},
+
+		// 关键字不区分大小写
+		{`"v2" IN Tags AND Zone == "z1"`, true},
+
+		// 优先级：not > and > or，括号可改变结合
+		{`Service == "db" and Port == 1 or Zone == "z1"`, true},
+		{`Zone == "z1" or Service == "db" and Port == 1`, true},
+		{`(Zone == "z1" or Service == "db") and Port == 1`, false},
+		{`not Service == "db" and Port == 8080`, true},
+		{`not (Service == "web" and Port == 8080)`, false},
+		{`not not Service == "web"`, true},
+		{`Service == "db" or Service == "api" or Service == "web"`, true},
+
+		// 引号与转义
+		{`Meta.note == "say \"hi\""`, true},
+		{`Meta.version == "1.4 "`, false},
+		{`"and" in Tags`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := f.Match(v); got != tt.want {
+				t.Fatalf("Match = %v, want %v", got, tt.want)
+			}
+			if f.String() != tt.expr {
+				t.Fatalf("String = %q, want %q", f.String(), tt.expr)
+			}
+		})
+	}
+
+	var nilFilter *Filter
+	if !nilFilter.Match(v) {
+		t.Fatalf("nil filter should match everything")
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // 错误信息应包含的片段
+	}{
+		{``, `unexpected "end of expression"`},
+		{`Service`, `expected operator after "Service"`},
+		{`Service = "web"`, `unexpected "="`},
+		{`Service == web`, `expected value but found "web"`},
+		{`Service == "web`, `unterminated string at position 11`},
+		{`Service == "a\q"`, `bad string`},
+		{`Bogus == "x"`, `unknown selector "Bogus"`},
+		{`Meta. == "x"`, `unknown selector "Meta."`},
+		{`"v2" in Bogus`, `unknown selector "Bogus"`},
+		{`"v2" Tags`, `expected "in" but found "Tags"`},
+		{`Tags == "v2"`, `selector "Tags" does not support ==`},
+		{`Meta != "x"`, `selector "Meta" does not support !=`},
+		{`Node is full`, `expected "empty" but found "full"`},
+		{`(Service == "web"`, `expected ")"`},
+		{`Service == "web")`, `unexpected ")" at position 16`},
+		{`Service == "web" and`, `unexpected "end of expression"`},
+		{`Service == "web" Port == 1`, `unexpected "Port"`},
+		{`Port == 80 & Zone == "z1"`, `unexpected "&"`},
+		{`Port == -`, `bad number`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err == nil {
+				t.Fatalf("expected error, got filter %q", f)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
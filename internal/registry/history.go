@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// history.go - 检查状态切换历史与抖动检测
+// 切换记录随 Check 一起保存（经 Raft 复制并进入快照）；抖动在读取时按当前时间计算。
+
+// 抖动检测的默认参数：10 分钟内切换超过 5 次视为抖动。
+const (
+	DefaultFlapThreshold = 5
+	DefaultFlapWindow    = 10 * time.Minute
+)
+
+// CheckHistory 返回检查的状态切换历史及是否处于抖动。
+func (m *memoryRegistry) CheckHistory(ctx context.Context, checkID string) (CheckHistory, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cr, ok := m.checks[checkID]
+	if !ok {
+		return CheckHistory{}, m.index, errors.New("check not found")
+	}
+	return m.checkHistoryLocked(cr.chk, time.Now()), m.index, nil
+}
+
+// ListFlappingChecks 列出当前处于抖动的检查（按检查 ID 排序）。
+func (m *memoryRegistry) ListFlappingChecks(ctx context.Context) ([]CheckHistory, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	out := []CheckHistory{}
+	for _, cr := range m.checks {
+		if m.isFlapping(cr.chk, now) {
+			out = append(out, m.checkHistoryLocked(cr.chk, now))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CheckID < out[j].CheckID })
+	return out, m.index, nil
+}
+
+// checkHistoryLocked 构造检查历史视图，并补全所属实例或节点。
+func (m *memoryRegistry) checkHistoryLocked(chk Check, now time.Time) CheckHistory {
+	h := CheckHistory{
+		CheckID:     chk.ID,
+		Status:      chk.Status.String(),
+		Flapping:    m.isFlapping(chk, now),
+		Transitions: append([]CheckTransition{}, chk.History...),
+	}
+	if k, ok := m.checkOwner[chk.ID]; ok {
+		if rec, ok := m.instances[k]; ok {
+			h.Namespace = rec.inst.Namespace
+			h.Service = rec.inst.Service
+			h.InstanceID = rec.inst.ID
+			h.Node = rec.inst.Node
+		}
+	} else if name, ok := m.checkNode[chk.ID]; ok {
+		h.Node = name
+	}
+	return h
+}
+
+// isFlapping 判断检查在 [now-flapWindow, now] 内的切换次数是否超过阈值。
+func (m *memoryRegistry) isFlapping(chk Check, now time.Time) bool {
+	since := now.Add(-m.flapWindow)
+	n := 0
+	for i := len(chk.History) - 1; i >= 0; i-- {
+		if chk.History[i].At.Before(since) {
+			break
+		}
+		n++
+	}
+	return n > m.flapThreshold
+}
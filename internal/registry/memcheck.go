@@ -0,0 +1,154 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// memcheck.go - memoryRegistry 的检查查询：返回检查最近一次上报的状态、输出与说明。
+// 按服务查询以服务索引阻塞；按状态查询跨越多个服务，以健康索引（healthIndex）阻塞。
+
+// GetCheck 返回单个检查的运行时状态。
+func (m *memoryRegistry) GetCheck(ctx context.Context, checkID string) (CheckView, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cr, ok := m.checks[checkID]
+	if !ok {
+		return CheckView{}, m.index, errors.New("check not found")
+	}
+	return m.checkViewLocked(cr.chk), m.index, nil
+}
+
+// ServiceChecks 列出服务所有实例的检查（不含节点检查），按检查 ID 排序；idx 为该服务的索引。
+func (m *memoryRegistry) ServiceChecks(ctx context.Context, namespace, service string) ([]CheckView, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	svc := m.svcKey(nsOrDefault(namespace), service)
+	out := []CheckView{}
+	for _, rec := range m.svcInstances[svc] {
+		for _, cid := range rec.checks {
+			if cr, ok := m.checks[cid]; ok {
+				out = append(out, m.checkViewLocked(cr.chk))
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CheckID < out[j].CheckID })
+	idx := m.svcIndex[svc]
+	if idx == 0 {
+		idx = m.index
+	}
+	return out, idx, nil
+}
+
+// ChecksInState 列出处于给定状态（passing/warning/critical，或 CheckStateAny）的检查，按检查 ID 排序。
+// namespace 为空时包含全部命名空间及节点检查，否则仅包含该命名空间下实例的检查；idx 为健康索引。
+func (m *memoryRegistry) ChecksInState(ctx context.Context, namespace, state string) ([]CheckView, uint64, error) {
+	switch state {
+	case CheckStateAny, StatusPassing.String(), StatusWarning.String(), StatusCritical.String():
+	default:
+		return nil, 0, fmt.Errorf("unknown check state: %q", state)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []CheckView{}
+	for _, cr := range m.checks {
+		if state != CheckStateAny && cr.chk.Status.String() != state {
+			continue
+		}
+		v := m.checkViewLocked(cr.chk)
+		if namespace != "" && v.Namespace != namespace {
+			continue
+		}
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CheckID < out[j].CheckID })
+	return out, m.healthIndexLocked(), nil
+}
+
+// ListInstanceHealth 与 ListHealthyInstances 相同，但每个实例附带聚合状态及其检查（实例检查在前，节点检查在后）。
+func (m *memoryRegistry) ListInstanceHealth(ctx context.Context, namespace, service string, opts ListOptions) ([]InstanceHealth, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	views, idx := m.listHealthyInstancesLocked(namespace, service, opts)
+	out := make([]InstanceHealth, 0, len(views))
+	for _, v := range views {
+		ih := InstanceHealth{InstanceView: v, Status: v.status.String(), Checks: []CheckView{}}
+		var cids []string
+		if rec, ok := m.instances[m.key(v.Namespace, v.Service, v.ID)]; ok {
+			cids = append(cids, rec.checks...)
+		}
+		if nrec, ok := m.nodes[v.Node]; ok && v.Node != "" {
+			cids = append(cids, nrec.checks...)
+		}
+		for _, cid := range cids {
+			if cr, ok := m.checks[cid]; ok {
+				ih.Checks = append(ih.Checks, m.checkViewLocked(cr.chk))
+			}
+		}
+		out = append(out, ih)
+	}
+	return out, idx, nil
+}
+
+// WatchHealth 等待健康索引超过 lastIndex；用于跨服务的检查查询长轮询。
+func (m *memoryRegistry) WatchHealth(ctx context.Context, lastIndex uint64) (uint64, <-chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	curr := m.healthIndexLocked()
+	ch := make(chan struct{}, 1)
+	if curr > lastIndex {
+		ch <- struct{}{}
+		close(ch)
+		return curr, ch
+	}
+	m.healthWatchers = append(m.healthWatchers, ch)
+	return curr, ch
+}
+
+// --- 内部方法 ---
+
+// checkViewLocked 构造检查视图，并补全所属实例或节点。
+func (m *memoryRegistry) checkViewLocked(chk Check) CheckView {
+	v := CheckView{
+		CheckID:    chk.ID,
+		Type:       chk.Spec.Type,
+		Status:     chk.Status.String(),
+		Output:     chk.Output,
+		Note:       chk.Note,
+		LastUpdate: chk.LastUpdate,
+	}
+	if k, ok := m.checkOwner[chk.ID]; ok {
+		if rec, ok := m.instances[k]; ok {
+			v.Namespace = rec.inst.Namespace
+			v.Service = rec.inst.Service
+			v.InstanceID = rec.inst.ID
+			v.Node = rec.inst.Node
+		}
+	} else if name, ok := m.checkNode[chk.ID]; ok {
+		v.Node = name
+	}
+	return v
+}
+
+// touchHealthLocked 将健康索引推进到当前全局索引，并通知其 Watchers。
+func (m *memoryRegistry) touchHealthLocked() {
+	m.healthIndex = m.index
+	for _, ch := range m.healthWatchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+		close(ch)
+	}
+	m.healthWatchers = nil
+}
+
+// healthIndexLocked 返回健康索引；快照恢复后尚未推进时回退到全局索引。
+func (m *memoryRegistry) healthIndexLocked() uint64 {
+	if m.healthIndex == 0 {
+		return m.index
+	}
+	return m.healthIndex
+}
@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// memconfig.go - memoryRegistry 的服务级配置项（service-defaults）
+// 配置项经 Raft 复制（opConfigSet/opConfigDel），注册实例时在 FSM 中合并，各节点结果一致。
+// 配置项变更会推进该服务的索引，使携带配置项的健康查询长轮询能及时返回；
+// 已注册实例不会被改写，合并结果在实例下次注册时生效。
+
+// UpsertServiceDefaults 创建或更新服务级配置项。
+func (m *memoryRegistry) UpsertServiceDefaults(ctx context.Context, d ServiceDefaults) (uint64, error) {
+	d.Namespace = nsOrDefault(d.Namespace)
+	if d.Name == "" {
+		return 0, errors.New("missing service Name")
+	}
+	if d.Weights.Passing < 0 || d.Weights.Warning < 0 {
+		return 0, errors.New("weights must be >= 0")
+	}
+	d, err := normalizeServiceDefaults(d)
+	if err != nil {
+		return 0, err
+	}
+	svc := m.svcKey(d.Namespace, d.Name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := m.nextIndexLocked(svc)
+	if old, ok := m.serviceDefaults[svc]; ok {
+		d.CreateIndex = old.CreateIndex
+	} else {
+		d.CreateIndex = idx
+	}
+	d.ModifyIndex = idx
+	m.serviceDefaults[svc] = &d
+	return idx, nil
+}
+
+func (m *memoryRegistry) DeleteServiceDefaults(ctx context.Context, namespace, name string) (uint64, error) {
+	svc := m.svcKey(nsOrDefault(namespace), name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.serviceDefaults[svc]; !ok {
+		return m.index, errors.New("service defaults not found")
+	}
+	delete(m.serviceDefaults, svc)
+	return m.nextIndexLocked(svc), nil
+}
+
+func (m *memoryRegistry) GetServiceDefaults(ctx context.Context, namespace, name string) (ServiceDefaults, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d, ok := m.serviceDefaults[m.svcKey(nsOrDefault(namespace), name)]
+	if !ok {
+		return ServiceDefaults{}, m.index, errors.New("service defaults not found")
+	}
+	return cloneServiceDefaults(*d), m.index, nil
+}
+
+// ListServiceDefaults 列出配置项；namespace 为空时列出全部命名空间。
+func (m *memoryRegistry) ListServiceDefaults(ctx context.Context, namespace string) ([]ServiceDefaults, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []ServiceDefaults{}
+	for _, d := range m.serviceDefaults {
+		if namespace == "" || d.Namespace == namespace {
+			out = append(out, cloneServiceDefaults(*d))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, m.index, nil
+}
+
+// --- 内部方法 ---
+
+// applyServiceDefaultsLocked 将服务级配置项合并到待注册的实例与检查中；实例已设置的字段不被覆盖。
+// 返回的 specs 为副本，不修改调用方的切片。
+func (m *memoryRegistry) applyServiceDefaultsLocked(inst ServiceInstance, specs []CheckSpec) (ServiceInstance, []CheckSpec) {
+	d, ok := m.serviceDefaults[m.svcKey(inst.Namespace, inst.Service)]
+	if !ok {
+		return inst, specs
+	}
+	if d.Protocol != "" || len(d.Meta) > 0 {
+		meta := cloneMap(inst.Meta)
+		if meta == nil {
+			meta = make(map[string]string, len(d.Meta)+1)
+		}
+		for k, v := range d.Meta {
+			if _, ok := meta[k]; !ok {
+				meta[k] = v
+			}
+		}
+		if _, ok := meta["protocol"]; !ok && d.Protocol != "" {
+			meta["protocol"] = d.Protocol
+		}
+		inst.Meta = meta
+	}
+	if inst.Weights.Passing == 0 {
+		inst.Weights.Passing = d.Weights.Passing
+	}
+	if inst.Weights.Warning == 0 {
+		inst.Weights.Warning = d.Weights.Warning
+	}
+	if d.TTL > 0 {
+		specs = append([]CheckSpec(nil), specs...)
+		for i := range specs {
+			if specs[i].Type == CheckTTL && specs[i].TTL == 0 && specs[i].TTLRaw == "" {
+				specs[i].TTL, specs[i].TTLRaw = d.TTL, d.TTLRaw
+			}
+		}
+	}
+	return inst, specs
+}
+
+// normalizeServiceDefaults 从原始字符串解析 TTL；与 CheckSpec 相同，时长字段不参与 JSON 编码。
+func normalizeServiceDefaults(d ServiceDefaults) (ServiceDefaults, error) {
+	if d.TTL == 0 && d.TTLRaw != "" {
+		ttl, err := time.ParseDuration(d.TTLRaw)
+		if err != nil {
+			return d, fmt.Errorf("bad TTL: %w", err)
+		}
+		d.TTL = ttl
+	}
+	if d.TTL < 0 {
+		return d, errors.New("TTL must be >= 0")
+	}
+	if d.TTL > 0 {
+		d.TTLRaw = d.TTL.String()
+	}
+	return d, nil
+}
+
+func cloneServiceDefaults(d ServiceDefaults) ServiceDefaults {
+	d.Meta = cloneMap(d.Meta)
+	return d
+}
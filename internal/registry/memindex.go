@@ -0,0 +1,261 @@
+package registry
+
+import (
+	"container/heap"
+	"time"
+)
+
+// memindex.go - memoryRegistry 的二级索引
+// 维护 服务->实例、命名空间->服务、检查->实例、节点->实例 等索引，
+// 使查询与 TTL 续约的开销只与相关服务/检查规模有关，而与目录总规模无关。
+// TTL 过期、critical 超时注销与会话过期另按截止时间建堆，过期扫描只访问已到期的条目。
+// 除注明外，所有方法均要求调用方持有写锁。
+
+// indexInstanceLocked 将新实例加入各索引
+func (m *memoryRegistry) indexInstanceLocked(k string, rec *instanceRecord) {
+	ns, name := rec.inst.Namespace, rec.inst.Service
+	svc := m.svcKey(ns, name)
+	if m.svcInstances[svc] == nil {
+		m.svcInstances[svc] = make(map[string]*instanceRecord)
+	}
+	m.svcInstances[svc][k] = rec
+	if m.nsServices[ns] == nil {
+		m.nsServices[ns] = make(map[string]struct{})
+	}
+	m.nsServices[ns][name] = struct{}{}
+	for _, cid := range rec.checks {
+		m.checkOwner[cid] = k
+	}
+	if node := rec.inst.Node; node != "" {
+		if m.nodeInstances[node] == nil {
+			m.nodeInstances[node] = make(map[string]struct{})
+		}
+		m.nodeInstances[node][k] = struct{}{}
+	}
+	m.idToKeys[rec.inst.ID] = appendUnique(m.idToKeys[rec.inst.ID], k)
+}
+
+// unindexInstanceLocked 将实例从各索引移除
+func (m *memoryRegistry) unindexInstanceLocked(k string, rec *instanceRecord) {
+	ns, name := rec.inst.Namespace, rec.inst.Service
+	svc := m.svcKey(ns, name)
+	if insts := m.svcInstances[svc]; insts != nil {
+		delete(insts, k)
+		if len(insts) == 0 {
+			delete(m.svcInstances, svc)
+			if svcs := m.nsServices[ns]; svcs != nil {
+				delete(svcs, name)
+				if len(svcs) == 0 {
+					delete(m.nsServices, ns)
+				}
+			}
+		}
+	}
+	for _, cid := range rec.checks {
+		if m.checkOwner[cid] == k {
+			delete(m.checkOwner, cid)
+		}
+	}
+	if node := rec.inst.Node; node != "" {
+		if insts := m.nodeInstances[node]; insts != nil {
+			delete(insts, k)
+			if len(insts) == 0 {
+				delete(m.nodeInstances, node)
+			}
+		}
+	}
+	keys := removeString(m.idToKeys[rec.inst.ID], k)
+	if len(keys) == 0 {
+		delete(m.idToKeys, rec.inst.ID)
+	} else {
+		m.idToKeys[rec.inst.ID] = keys
+	}
+}
+
+// rebuildIndexesLocked 依据 instances 全量重建二级索引（用于快照恢复）
+func (m *memoryRegistry) rebuildIndexesLocked() {
+	m.svcInstances = make(map[string]map[string]*instanceRecord)
+	m.nsServices = make(map[string]map[string]struct{})
+	m.checkOwner = make(map[string]string, len(m.checks))
+	m.idToKeys = make(map[string][]string, len(m.instances))
+	m.nodeInstances = make(map[string]map[string]struct{})
+	for k, rec := range m.instances {
+		m.indexInstanceLocked(k, rec)
+	}
+	m.checkNode = make(map[string]string)
+	for name, rec := range m.nodes {
+		for _, cid := range rec.checks {
+			m.checkNode[cid] = name
+		}
+	}
+}
+
+// rebuildDeadlinesLocked 依据 checks 与 sessions 全量重建截止时间索引与 检查->会话 索引（用于快照恢复）
+func (m *memoryRegistry) rebuildDeadlinesLocked() {
+	m.ttlDeadlines = deadlineIndex{}
+	m.reapDeadlines = deadlineIndex{}
+	m.sessionDeadlines = deadlineIndex{}
+	m.checkSessions = make(map[string]map[string]struct{})
+	m.staleChecks = make(map[string]struct{})
+	for _, s := range m.sessions {
+		m.indexSessionLocked(s)
+	}
+	for cid := range m.checks {
+		m.touchCheckLocked(cid)
+	}
+}
+
+// putCheckLocked 写入检查并同步截止时间索引
+func (m *memoryRegistry) putCheckLocked(cid string, chk Check) {
+	m.checks[cid] = &checkRecord{chk: chk}
+	m.touchCheckLocked(cid)
+}
+
+// deleteCheckLocked 删除检查并同步截止时间索引
+func (m *memoryRegistry) deleteCheckLocked(cid string) {
+	delete(m.checks, cid)
+	m.touchCheckLocked(cid)
+}
+
+// touchCheckLocked 在检查的状态或续约时间原地变化后调用，重新计算其截止时间；
+// 检查已删除或处于 critical 且有关联会话时记入 staleChecks，由 invalidateSessionsLocked 处理。
+func (m *memoryRegistry) touchCheckLocked(cid string) {
+	cr, ok := m.checks[cid]
+	if !ok {
+		m.ttlDeadlines.remove(cid)
+		m.reapDeadlines.remove(cid)
+	} else {
+		chk := cr.chk
+		if chk.Spec.Type == CheckTTL && chk.Spec.TTL > 0 && !chk.LastPass.IsZero() && chk.Status != StatusCritical {
+			m.ttlDeadlines.set(cid, chk.LastPass.Add(chk.Spec.TTL))
+		} else {
+			m.ttlDeadlines.remove(cid)
+		}
+		if dca := chk.Spec.DeregisterCriticalAfter; dca > 0 && chk.Status == StatusCritical && !chk.CriticalSince.IsZero() {
+			m.reapDeadlines.set(cid, chk.CriticalSince.Add(dca))
+		} else {
+			m.reapDeadlines.remove(cid)
+		}
+	}
+	if (!ok || cr.chk.Status == StatusCritical) && len(m.checkSessions[cid]) > 0 {
+		m.staleChecks[cid] = struct{}{}
+	}
+}
+
+// indexSessionLocked 将会话加入 检查->会话 索引并更新其过期时间（续约时重复调用即可）
+func (m *memoryRegistry) indexSessionLocked(s *Session) {
+	for _, cid := range s.Checks {
+		if m.checkSessions[cid] == nil {
+			m.checkSessions[cid] = make(map[string]struct{})
+		}
+		m.checkSessions[cid][s.ID] = struct{}{}
+	}
+	if s.TTL > 0 {
+		m.sessionDeadlines.set(s.ID, s.LastRenew.Add(s.TTL))
+	}
+}
+
+// unindexSessionLocked 将会话从 检查->会话 索引与过期时间索引移除
+func (m *memoryRegistry) unindexSessionLocked(s *Session) {
+	for _, cid := range s.Checks {
+		if ids := m.checkSessions[cid]; ids != nil {
+			delete(ids, s.ID)
+			if len(ids) == 0 {
+				delete(m.checkSessions, cid)
+			}
+		}
+	}
+	m.sessionDeadlines.remove(s.ID)
+}
+
+// deadlineEntry 为截止时间堆中的一项
+type deadlineEntry struct {
+	id string
+	at time.Time
+}
+
+// deadlineIndex 是按截止时间排序的最小堆，每个 ID 至多一项；pos 记录 ID 在堆中的位置以便原地更新。
+// 通过 container/heap 维护，零值可直接使用。
+type deadlineIndex struct {
+	items []deadlineEntry
+	pos   map[string]int
+}
+
+func (d *deadlineIndex) Len() int           { return len(d.items) }
+func (d *deadlineIndex) Less(i, j int) bool { return d.items[i].at.Before(d.items[j].at) }
+
+func (d *deadlineIndex) Swap(i, j int) {
+	d.items[i], d.items[j] = d.items[j], d.items[i]
+	d.pos[d.items[i].id] = i
+	d.pos[d.items[j].id] = j
+}
+
+func (d *deadlineIndex) Push(x any) {
+	e := x.(deadlineEntry)
+	d.pos[e.id] = len(d.items)
+	d.items = append(d.items, e)
+}
+
+func (d *deadlineIndex) Pop() any {
+	n := len(d.items) - 1
+	e := d.items[n]
+	d.items = d.items[:n]
+	delete(d.pos, e.id)
+	return e
+}
+
+// set 设置或更新 ID 的截止时间
+func (d *deadlineIndex) set(id string, at time.Time) {
+	if d.pos == nil {
+		d.pos = make(map[string]int)
+	}
+	if i, ok := d.pos[id]; ok {
+		d.items[i].at = at
+		heap.Fix(d, i)
+		return
+	}
+	heap.Push(d, deadlineEntry{id: id, at: at})
+}
+
+// remove 移除 ID 的截止时间（不存在时忽略）
+func (d *deadlineIndex) remove(id string) {
+	if i, ok := d.pos[id]; ok {
+		heap.Remove(d, i)
+	}
+}
+
+// due 返回截止时间不晚于 now 的 ID（无序）。只读，持有读锁即可调用。
+// 按堆序剪枝：某项未到期则其子树均未到期，开销只与到期项数量有关。
+func (d *deadlineIndex) due(now time.Time) []string {
+	var ids []string
+	var walk func(i int)
+	walk = func(i int) {
+		if i >= len(d.items) || d.items[i].at.After(now) {
+			return
+		}
+		ids = append(ids, d.items[i].id)
+		walk(2*i + 1)
+		walk(2*i + 2)
+	}
+	walk(0)
+	return ids
+}
+
+func appendUnique(lst []string, s string) []string {
+	for _, v := range lst {
+		if v == s {
+			return lst
+		}
+	}
+	return append(lst, s)
+}
+
+func removeString(lst []string, s string) []string {
+	out := lst[:0]
+	for _, v := range lst {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestDeadlineIndex(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	var d deadlineIndex
+	for i := 0; i < 10; i++ {
+		d.set(fmt.Sprintf("id%d", i), t0.Add(time.Duration(i)*time.Second))
+	}
+	due := func(sec int) []string {
+		ids := d.due(t0.Add(time.Duration(sec) * time.Second))
+		sort.Strings(ids)
+		return ids
+	}
+	if got := due(-1); len(got) != 0 {
+		t.Fatalf("due before any deadline = %v, want none", got)
+	}
+	if got := fmt.Sprint(due(2)); got != "[id0 id1 id2]" {
+		t.Fatalf("due(2) = %s", got)
+	}
+
+	d.set("id1", t0.Add(time.Minute)) // 推迟
+	d.set("id9", t0)                  // 提前
+	d.remove("id0")
+	d.remove("missing")
+	if got := fmt.Sprint(due(2)); got != "[id2 id9]" {
+		t.Fatalf("due(2) after update = %s", got)
+	}
+	if d.Len() != 9 || len(d.pos) != 9 {
+		t.Fatalf("len = %d, pos = %d, want 9", d.Len(), len(d.pos))
+	}
+	for id, i := range d.pos {
+		if d.items[i].id != id {
+			t.Fatalf("pos[%s] = %d points to %s", id, i, d.items[i].id)
+		}
+	}
+}
+
+func TestExpiryFollowsDeadlineIndexes(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0)
+	spec := CheckSpec{Type: CheckTTL, TTL: 10 * time.Second, DeregisterCriticalAfter: time.Minute}
+	var cids []string
+	for i := 0; i < 3; i++ {
+		_, ids, err := m.registerAt(ServiceInstance{Service: "web", ID: fmt.Sprintf("w%d", i)}, []CheckSpec{spec}, t0)
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+		cids = append(cids, ids[0])
+		m.renewTTLAt(ids[0], t0)
+	}
+	sess, _, err := m.createSessionAt(Session{ID: "s1", Checks: []string{cids[0]}, TTLRaw: "30s"}, t0)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	// w1 持续续约，w0/w2 超时
+	now := t0.Add(8 * time.Second)
+	m.renewTTLAt(cids[1], now)
+	now = t0.Add(15 * time.Second)
+	m.mu.RLock()
+	expired := m.expiredChecksLocked(now)
+	m.mu.RUnlock()
+	if got, want := fmt.Sprint(expired), fmt.Sprint([]string{cids[0], cids[2]}); got != want {
+		t.Fatalf("expired = %s, want %s", got, want)
+	}
+	m.expireChecksAt(expired, now)
+	if _, _, err := m.GetSession(context.Background(), sess.ID); err == nil {
+		t.Fatalf("session bound to expired check should be invalidated")
+	}
+	m.mu.RLock()
+	expired = m.expiredChecksLocked(now)
+	m.mu.RUnlock()
+	if len(expired) != 0 {
+		t.Fatalf("expired after marking critical = %v, want none", expired)
+	}
+
+	// w2 恢复；w0 持续 critical 超过 DeregisterCriticalAfter 后可注销
+	m.renewTTLAt(cids[2], now.Add(time.Second))
+	later := now.Add(2 * time.Minute)
+	m.mu.RLock()
+	keys := m.reapableInstancesLocked(later)
+	m.mu.RUnlock()
+	if got, want := fmt.Sprint(keys), fmt.Sprint([]string{m.key(DefaultNamespace, "web", "w0")}); got != want {
+		t.Fatalf("reapable = %s, want %s", got, want)
+	}
+	m.reapInstancesAt(keys, later)
+	if m.ttlDeadlines.Len() != 2 || m.reapDeadlines.Len() != 0 {
+		t.Fatalf("deadlines after reap: ttl=%d reap=%d, want 2/0", m.ttlDeadlines.Len(), m.reapDeadlines.Len())
+	}
+}
+
+func TestSessionExpiryFollowsRenew(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0)
+	for _, id := range []string{"a", "b"} {
+		if _, _, err := m.createSessionAt(Session{ID: id, TTLRaw: "10s"}, t0); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+	}
+	m.renewSessionAt("b", t0.Add(5*time.Second))
+	now := t0.Add(12 * time.Second)
+	m.mu.RLock()
+	ids := m.expiredSessionsLocked(now)
+	m.mu.RUnlock()
+	if fmt.Sprint(ids) != "[a]" {
+		t.Fatalf("expired sessions = %v, want [a]", ids)
+	}
+	m.expireSessionsAt(ids, now)
+	if m.sessionDeadlines.Len() != 1 {
+		t.Fatalf("session deadlines = %d, want 1", m.sessionDeadlines.Len())
+	}
+}
+
+// benchCatalog 构造含 n 个实例（每服务 100 个、各带一个 TTL 检查）的注册表，返回其中一个检查 ID。
+func benchCatalog(b *testing.B, n int) (*memoryRegistry, string) {
+	b.Helper()
+	m := NewMemoryRegistryWithOptions(Options{})
+	now := time.Now()
+	spec := CheckSpec{Type: CheckTTL, TTL: time.Hour}
+	var cid string
+	for i := 0; i < n; i++ {
+		inst := ServiceInstance{Service: fmt.Sprintf("svc%d", i/100), ID: fmt.Sprintf("inst%d", i), Address: "10.0.0.1", Port: 8080}
+		_, ids, err := m.registerAt(inst, []CheckSpec{spec}, now)
+		if err != nil {
+			b.Fatal(err)
+		}
+		m.renewTTLAt(ids[0], now)
+		cid = ids[0]
+	}
+	return m, cid
+}
+
+var benchSizes = []int{1000, 10000, 100000}
+
+func BenchmarkRenewTTL(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("instances=%d", n), func(b *testing.B) {
+			m, cid := benchCatalog(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.RenewTTL(context.Background(), cid); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkListHealthyInstances(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("instances=%d", n), func(b *testing.B) {
+			m, _ := benchCatalog(b, n)
+			opts := ListOptions{PassingOnly: true}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := m.ListHealthyInstances(context.Background(), DefaultNamespace, "svc0", opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExpireScan(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("instances=%d", n), func(b *testing.B) {
+			m, _ := benchCatalog(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.expireOnce()
+			}
+		})
+	}
+}
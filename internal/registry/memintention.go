@@ -0,0 +1,179 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// memintention.go - memoryRegistry 的服务间调用规则（intentions）
+// 规则与默认策略经 Raft 复制（opIntentionSet/opIntentionDel/opIntentionConfig）；评估为只读操作，在本地内存上进行。
+// 精确度（Precedence）参照常见服务网格的做法：目标越精确优先级越高，目标相同时比较源：
+//
+//	目标 ns/name + 源 ns/name = 9    目标 ns/* + 源 ns/name = 6    目标 */* + 源 ns/name = 3
+//	目标 ns/name + 源 ns/*    = 8    目标 ns/* + 源 ns/*    = 5    目标 */* + 源 ns/*    = 2
+//	目标 ns/name + 源 */*     = 7    目标 ns/* + 源 */*     = 4    目标 */* + 源 */*     = 1
+
+// UpsertIntention 创建或更新规则；ID 为空时自动生成。返回规则 ID。
+func (m *memoryRegistry) UpsertIntention(ctx context.Context, ix Intention) (string, uint64, error) {
+	if ix.ID == "" {
+		ix.ID = newUUID()
+	}
+	ix, err := normalizeIntention(ix)
+	if err != nil {
+		return "", 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, other := range m.intentions {
+		if id != ix.ID && sameIntentionTarget(*other, ix) {
+			return "", m.index, fmt.Errorf("intention %s/%s -> %s/%s already exists: %s",
+				ix.SourceNS, ix.SourceName, ix.DestinationNS, ix.DestinationName, id)
+		}
+	}
+	m.index++
+	if old, ok := m.intentions[ix.ID]; ok {
+		ix.CreateIndex = old.CreateIndex
+	} else {
+		ix.CreateIndex = m.index
+	}
+	ix.ModifyIndex = m.index
+	m.intentions[ix.ID] = &ix
+	return ix.ID, m.index, nil
+}
+
+func (m *memoryRegistry) DeleteIntention(ctx context.Context, id string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.intentions[id]; !ok {
+		return m.index, errors.New("intention not found")
+	}
+	delete(m.intentions, id)
+	m.index++
+	return m.index, nil
+}
+
+func (m *memoryRegistry) GetIntention(ctx context.Context, id string) (Intention, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ix, ok := m.intentions[id]
+	if !ok {
+		return Intention{}, m.index, errors.New("intention not found")
+	}
+	return *ix, m.index, nil
+}
+
+// ListIntentions 按评估顺序（Precedence 降序）列出规则。
+func (m *memoryRegistry) ListIntentions(ctx context.Context) ([]Intention, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Intention, 0, len(m.intentions))
+	for _, ix := range m.intentions {
+		out = append(out, *ix)
+	}
+	sortIntentions(out)
+	return out, m.index, nil
+}
+
+// CheckIntention 评估 source 调用 destination 是否被允许；二者须为精确的命名空间与服务名。
+// 没有匹配规则时按默认策略（IntentionConfig.DefaultDeny）决定。
+func (m *memoryRegistry) CheckIntention(ctx context.Context, srcNS, src, dstNS, dst string) (IntentionCheck, uint64, error) {
+	srcNS, dstNS = nsOrDefault(srcNS), nsOrDefault(dstNS)
+	if src == "" || dst == "" || src == IntentionWildcard || dst == IntentionWildcard ||
+		srcNS == IntentionWildcard || dstNS == IntentionWildcard {
+		return IntentionCheck{}, 0, errors.New("source and destination must be exact services")
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var best *Intention
+	for _, ix := range m.intentions {
+		if !intentionPartMatch(ix.SourceNS, ix.SourceName, srcNS, src) ||
+			!intentionPartMatch(ix.DestinationNS, ix.DestinationName, dstNS, dst) {
+			continue
+		}
+		if best == nil || ix.Precedence > best.Precedence {
+			best = ix
+		}
+	}
+	if best == nil {
+		return IntentionCheck{Allowed: !m.intentionConfig.DefaultDeny}, m.index, nil
+	}
+	cp := *best
+	return IntentionCheck{Allowed: cp.Action == IntentionAllow, Intention: &cp}, m.index, nil
+}
+
+// SetIntentionConfig 更新调用规则的全局配置。
+func (m *memoryRegistry) SetIntentionConfig(ctx context.Context, c IntentionConfig) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.index++
+	c.ModifyIndex = m.index
+	m.intentionConfig = c
+	return m.index, nil
+}
+
+func (m *memoryRegistry) GetIntentionConfig(ctx context.Context) (IntentionConfig, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.intentionConfig, m.index, nil
+}
+
+// --- 内部方法 ---
+
+// normalizeIntention 补全默认命名空间、校验通配符与动作，并计算 Precedence。
+func normalizeIntention(ix Intention) (Intention, error) {
+	ix.SourceNS, ix.DestinationNS = nsOrDefault(ix.SourceNS), nsOrDefault(ix.DestinationNS)
+	if ix.SourceName == "" || ix.DestinationName == "" {
+		return ix, errors.New("missing SourceName/DestinationName")
+	}
+	if ix.SourceNS == IntentionWildcard && ix.SourceName != IntentionWildcard {
+		return ix, errors.New("SourceName must be * when SourceNS is *")
+	}
+	if ix.DestinationNS == IntentionWildcard && ix.DestinationName != IntentionWildcard {
+		return ix, errors.New("DestinationName must be * when DestinationNS is *")
+	}
+	switch ix.Action {
+	case IntentionAllow, IntentionDeny:
+	default:
+		return ix, fmt.Errorf("Action must be allow or deny: %q", ix.Action)
+	}
+	ix.Precedence = 3*intentionExactness(ix.DestinationNS, ix.DestinationName) + intentionExactness(ix.SourceNS, ix.SourceName) + 1
+	return ix, nil
+}
+
+// intentionExactness 返回一侧的精确度：*/* 为 0，ns/* 为 1，ns/name 为 2。
+func intentionExactness(ns, name string) int {
+	switch {
+	case ns == IntentionWildcard:
+		return 0
+	case name == IntentionWildcard:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// intentionPartMatch 判断规则的一侧（ns/name，可含通配符）是否匹配具体服务。
+func intentionPartMatch(ns, name, svcNS, svc string) bool {
+	return (ns == IntentionWildcard || ns == svcNS) && (name == IntentionWildcard || name == svc)
+}
+
+func sameIntentionTarget(a, b Intention) bool {
+	return a.SourceNS == b.SourceNS && a.SourceName == b.SourceName &&
+		a.DestinationNS == b.DestinationNS && a.DestinationName == b.DestinationName
+}
+
+// sortIntentions 按 Precedence 降序排序，相同时按目标、源排序，保证输出稳定。
+func sortIntentions(ixs []Intention) {
+	sort.Slice(ixs, func(i, j int) bool {
+		a, b := ixs[i], ixs[j]
+		if a.Precedence != b.Precedence {
+			return a.Precedence > b.Precedence
+		}
+		if a.DestinationNS+"/"+a.DestinationName != b.DestinationNS+"/"+b.DestinationName {
+			return a.DestinationNS+"/"+a.DestinationName < b.DestinationNS+"/"+b.DestinationName
+		}
+		return a.SourceNS+"/"+a.SourceName < b.SourceNS+"/"+b.SourceName
+	})
+}
@@ -0,0 +1,272 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memkv.go - memoryRegistry 的键值存储
+// 键值写操作经 Raft 复制（opKV）；读与阻塞查询直接在本地内存上进行。
+// 删除的键保留墓碑索引，保证删除后阻塞查询返回的索引单调递增；墓碑保留 KVTombstoneTTL 后由
+// 过期器按索引水位回收（opReapTombstones），回收的最大索引记入 kvReapIndex 作为查询索引下限。
+// 带 Session 的条目即为锁，加锁/释放见 KVLock/KVUnlock，会话失效的处理见 memsession.go。
+
+// 墓碑回收参数：墓碑至少保留 KVTombstoneTTL，过期器每隔 KVTombstoneGCInterval 尝试回收一次。
+const (
+	KVTombstoneTTL        = 15 * time.Minute
+	KVTombstoneGCInterval = time.Minute
+)
+
+type kvWatcher struct {
+	prefix  string
+	recurse bool // true 时监听前缀，否则仅监听该键
+	ch      chan struct{}
+}
+
+// KVApply 执行一次键值写操作；ok 为 false 表示 CAS 或加锁条件不满足（未写入）。
+func (m *memoryRegistry) KVApply(ctx context.Context, op KVOp) (bool, uint64, error) {
+	return m.kvApplyAt(op, time.Now())
+}
+
+// kvApplyAt 以给定时间执行键值写操作；时间仅用于判断 lock-delay。
+func (m *memoryRegistry) kvApplyAt(op KVOp, now time.Time) (bool, uint64, error) {
+	if op.Key == "" && op.Verb != KVDeleteTree {
+		return false, 0, errors.New("missing key")
+	}
+	if len(op.Value) > MaxKVValueSize {
+		return false, 0, fmt.Errorf("value too large: max %d bytes", MaxKVValueSize)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, exists := m.kv[op.Key]
+	switch op.Verb {
+	case KVSet:
+	case KVCAS:
+		if (op.Index == 0 && exists) || (op.Index != 0 && (!exists || cur.ModifyIndex != op.Index)) {
+			return false, m.index, nil
+		}
+	case KVDelete:
+		if !exists {
+			return true, m.index, nil
+		}
+		m.kvDeleteLocked(op.Key)
+		return true, m.index, nil
+	case KVDeleteCAS:
+		if !exists || cur.ModifyIndex != op.Index {
+			return false, m.index, nil
+		}
+		m.kvDeleteLocked(op.Key)
+		return true, m.index, nil
+	case KVLock:
+		if _, ok := m.sessions[op.Session]; !ok {
+			return false, m.index, errors.New("invalid session: " + op.Session)
+		}
+		// 同一会话重复加锁视为更新值；被其他会话持有或处于 lock-delay 时失败
+		if exists && cur.Session != "" && cur.Session != op.Session {
+			return false, m.index, nil
+		}
+		if (!exists || cur.Session == "") && m.lockDelayedLocked(op.Key, now) {
+			return false, m.index, nil
+		}
+	case KVUnlock:
+		if !exists || op.Session == "" || cur.Session != op.Session {
+			return false, m.index, nil
+		}
+	case KVDeleteTree:
+		var keys []string
+		for k := range m.kv {
+			if strings.HasPrefix(k, op.Key) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			m.kvDeleteLocked(k)
+		}
+		return true, m.index, nil
+	default:
+		return false, m.index, fmt.Errorf("unknown kv verb: %s", op.Verb)
+	}
+
+	// 普通写入不影响锁的持有状态
+	m.index++
+	e := &KVEntry{Key: op.Key, Value: op.Value, Flags: op.Flags, CreateIndex: m.index, ModifyIndex: m.index}
+	if exists {
+		e.CreateIndex, e.LockIndex, e.Session = cur.CreateIndex, cur.LockIndex, cur.Session
+	}
+	switch op.Verb {
+	case KVLock:
+		if e.Session != op.Session {
+			e.Session = op.Session
+			e.LockIndex++
+		}
+	case KVUnlock:
+		e.Session = ""
+	}
+	m.kv[op.Key] = e
+	delete(m.kvTombstones, op.Key)
+	m.notifyKVLocked(op.Key)
+	return true, m.index, nil
+}
+
+// KVGet 返回单个键；键不存在时 entry 为 nil。idx 为该键最近一次修改或删除的索引。
+func (m *memoryRegistry) KVGet(ctx context.Context, key string) (*KVEntry, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx := m.kvIndexLocked(key, false)
+	e, ok := m.kv[key]
+	if !ok {
+		return nil, idx, nil
+	}
+	cp := *e
+	return &cp, idx, nil
+}
+
+// KVList 返回以 prefix 为前缀的全部条目（按键排序）。idx 为前缀下最近一次修改或删除的索引。
+func (m *memoryRegistry) KVList(ctx context.Context, prefix string) ([]KVEntry, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []KVEntry{}
+	for k, e := range m.kv {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, *e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, m.kvIndexLocked(prefix, true), nil
+}
+
+// KVWatch 监听键（recurse 时为前缀）的变化；若 lastIndex 落后，会立刻触发一次通知。
+func (m *memoryRegistry) KVWatch(ctx context.Context, key string, recurse bool, lastIndex uint64) (uint64, <-chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	curr := m.kvIndexLocked(key, recurse)
+	ch := make(chan struct{}, 1)
+	if curr > lastIndex {
+		ch <- struct{}{}
+		close(ch)
+		return curr, ch
+	}
+	m.kvWatchers = append(m.kvWatchers, kvWatcher{prefix: key, recurse: recurse, ch: ch})
+	return curr, ch
+}
+
+// --- 内部方法 ---
+
+func (m *memoryRegistry) kvDeleteLocked(key string) {
+	m.index++
+	delete(m.kv, key)
+	m.kvTombstones[key] = m.index
+	m.notifyKVLocked(key)
+}
+
+// kvIndexLocked 返回匹配键（含墓碑）的最大修改索引；已回收的墓碑以 kvReapIndex 兜底，
+// 使回收前后返回的索引不回退。
+func (m *memoryRegistry) kvIndexLocked(key string, recurse bool) uint64 {
+	if !recurse {
+		if e, ok := m.kv[key]; ok {
+			return e.ModifyIndex
+		}
+		if idx, ok := m.kvTombstones[key]; ok {
+			return idx
+		}
+		return m.kvReapIndex
+	}
+	idx := m.kvReapIndex
+	for k, e := range m.kv {
+		if e.ModifyIndex > idx && strings.HasPrefix(k, key) {
+			idx = e.ModifyIndex
+		}
+	}
+	for k, v := range m.kvTombstones {
+		if v > idx && strings.HasPrefix(k, key) {
+			idx = v
+		}
+	}
+	return idx
+}
+
+// reapTombstones 回收索引不超过 index 的墓碑，并将 kvReapIndex 推进到被回收的最大索引。
+// 回收不改变可见数据，因此不推进全局索引，也不通知 Watchers。
+func (m *memoryRegistry) reapTombstones(index uint64) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.kvTombstones {
+		if v <= index {
+			delete(m.kvTombstones, k)
+			if v > m.kvReapIndex {
+				m.kvReapIndex = v
+			}
+		}
+	}
+	return m.index
+}
+
+// tombstoneWatermarkLocked 返回可回收墓碑的索引水位（0 表示本轮无需回收）。调用方需持有读锁。
+func (m *memoryRegistry) tombstoneWatermarkLocked(now time.Time) uint64 {
+	w := m.tombGC.watermark(now, m.index)
+	if w == 0 {
+		return 0
+	}
+	for _, v := range m.kvTombstones {
+		if v <= w {
+			return w
+		}
+	}
+	return 0
+}
+
+// indexSample 记录某一时刻的全局索引
+type indexSample struct {
+	at    time.Time
+	index uint64
+}
+
+// tombstoneGC 记录全局索引随时间的采样，用于把「删除超过 KVTombstoneTTL」换算为索引水位。
+// 采样只在执行过期器的节点本地进行（新 Leader 需重新积累 KVTombstoneTTL 才开始回收），
+// 回收命令本身只携带水位，各节点应用结果一致。仅由过期器 goroutine 访问。
+type tombstoneGC struct {
+	samples  []indexSample
+	lastReap time.Time
+}
+
+// watermark 记录 (now, index) 采样，并在距上次回收超过 KVTombstoneGCInterval 时
+// 返回 KVTombstoneTTL 之前的全局索引；早于该时刻的采样随之丢弃。
+func (g *tombstoneGC) watermark(now time.Time, index uint64) uint64 {
+	if n := len(g.samples); n == 0 || g.samples[n-1].index != index {
+		g.samples = append(g.samples, indexSample{at: now, index: index})
+	}
+	if now.Sub(g.lastReap) < KVTombstoneGCInterval {
+		return 0
+	}
+	cutoff := now.Add(-KVTombstoneTTL)
+	i := sort.Search(len(g.samples), func(i int) bool { return g.samples[i].at.After(cutoff) }) - 1
+	if i < 0 {
+		return 0
+	}
+	g.samples = g.samples[i:]
+	g.lastReap = now
+	return g.samples[0].index
+}
+
+// notifyKVLocked 通知监听 key 本身或其前缀的 Watchers。
+func (m *memoryRegistry) notifyKVLocked(key string) {
+	kept := m.kvWatchers[:0]
+	for _, w := range m.kvWatchers {
+		if (w.recurse && strings.HasPrefix(key, w.prefix)) || (!w.recurse && key == w.prefix) {
+			select {
+			case w.ch <- struct{}{}:
+			default:
+			}
+			close(w.ch)
+			continue
+		}
+		kept = append(kept, w)
+	}
+	m.kvWatchers = kept
+}
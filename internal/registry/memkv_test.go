@@ -0,0 +1,236 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKVApplyConditions(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	// 每个用例在全新注册表上执行：先写入 k（ModifyIndex 为 1），会话 s1、s2 已创建，
+	// setup 中的操作预先执行，最后执行 op 并校验结果
+	tests := []struct {
+		name    string
+		setup   []KVOp
+		op      KVOp
+		wantOK  bool
+		wantErr bool
+		check   func(t *testing.T, e *KVEntry)
+	}{
+		{name: "cas create when absent", op: KVOp{Verb: KVCAS, Key: "new", Index: 0}, wantOK: true},
+		{name: "cas create when present", op: KVOp{Verb: KVCAS, Key: "k", Index: 0}, wantOK: false},
+		{name: "cas matching index", op: KVOp{Verb: KVCAS, Key: "k", Value: []byte("v2"), Index: 1}, wantOK: true,
+			check: func(t *testing.T, e *KVEntry) {
+				if string(e.Value) != "v2" || e.CreateIndex != 1 || e.ModifyIndex <= 1 {
+					t.Fatalf("entry = %+v", e)
+				}
+			}},
+		{name: "cas stale index", setup: []KVOp{{Verb: KVSet, Key: "k"}}, op: KVOp{Verb: KVCAS, Key: "k", Index: 1}, wantOK: false},
+		{name: "cas nonzero index on absent key", op: KVOp{Verb: KVCAS, Key: "new", Index: 1}, wantOK: false},
+		{name: "delete-cas matching index", op: KVOp{Verb: KVDeleteCAS, Key: "k", Index: 1}, wantOK: true,
+			check: func(t *testing.T, e *KVEntry) {
+				if e != nil {
+					t.Fatalf("entry still present: %+v", e)
+				}
+			}},
+		{name: "delete-cas stale index", op: KVOp{Verb: KVDeleteCAS, Key: "k", Index: 2}, wantOK: false},
+		{name: "delete missing key", op: KVOp{Verb: KVDelete, Key: "new"}, wantOK: true},
+		{name: "lock acquires", op: KVOp{Verb: KVLock, Key: "k", Session: "s1"}, wantOK: true,
+			check: func(t *testing.T, e *KVEntry) {
+				if e.Session != "s1" || e.LockIndex != 1 {
+					t.Fatalf("entry = %+v", e)
+				}
+			}},
+		{name: "lock held by other session", setup: []KVOp{{Verb: KVLock, Key: "k", Session: "s1"}}, op: KVOp{Verb: KVLock, Key: "k", Session: "s2"}, wantOK: false},
+		{name: "relock by holder keeps lock index", setup: []KVOp{{Verb: KVLock, Key: "k", Session: "s1"}}, op: KVOp{Verb: KVLock, Key: "k", Value: []byte("v2"), Session: "s1"}, wantOK: true,
+			check: func(t *testing.T, e *KVEntry) {
+				if e.Session != "s1" || e.LockIndex != 1 || string(e.Value) != "v2" {
+					t.Fatalf("entry = %+v", e)
+				}
+			}},
+		{name: "lock with unknown session", op: KVOp{Verb: KVLock, Key: "k", Session: "nope"}, wantOK: false, wantErr: true},
+		{name: "set keeps lock", setup: []KVOp{{Verb: KVLock, Key: "k", Session: "s1"}}, op: KVOp{Verb: KVSet, Key: "k", Value: []byte("v2")}, wantOK: true,
+			check: func(t *testing.T, e *KVEntry) {
+				if e.Session != "s1" {
+					t.Fatalf("plain set released lock: %+v", e)
+				}
+			}},
+		{name: "unlock by holder", setup: []KVOp{{Verb: KVLock, Key: "k", Session: "s1"}}, op: KVOp{Verb: KVUnlock, Key: "k", Session: "s1"}, wantOK: true,
+			check: func(t *testing.T, e *KVEntry) {
+				if e.Session != "" || e.LockIndex != 1 {
+					t.Fatalf("entry = %+v", e)
+				}
+			}},
+		{name: "unlock by other session", setup: []KVOp{{Verb: KVLock, Key: "k", Session: "s1"}}, op: KVOp{Verb: KVUnlock, Key: "k", Session: "s2"}, wantOK: false},
+		{name: "unlock when not locked", op: KVOp{Verb: KVUnlock, Key: "k", Session: "s1"}, wantOK: false},
+		{name: "relock after unlock bumps lock index",
+			setup:  []KVOp{{Verb: KVLock, Key: "k", Session: "s1"}, {Verb: KVUnlock, Key: "k", Session: "s1"}},
+			op:     KVOp{Verb: KVLock, Key: "k", Session: "s2"},
+			wantOK: true,
+			check: func(t *testing.T, e *KVEntry) {
+				if e.Session != "s2" || e.LockIndex != 2 {
+					t.Fatalf("entry = %+v", e)
+				}
+			}},
+		{name: "missing key", op: KVOp{Verb: KVSet}, wantErr: true},
+		{name: "value too large", op: KVOp{Verb: KVSet, Key: "k", Value: make([]byte, MaxKVValueSize+1)}, wantErr: true},
+		{name: "unknown verb", op: KVOp{Verb: "bogus", Key: "k"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemoryRegistryWithOptions(Options{})
+			if _, _, err := m.kvApplyAt(KVOp{Verb: KVSet, Key: "k", Value: []byte("v")}, t0); err != nil {
+				t.Fatalf("seed: %v", err)
+			}
+			for _, id := range []string{"s1", "s2"} {
+				if _, _, err := m.createSessionAt(Session{ID: id}, t0); err != nil {
+					t.Fatalf("create session: %v", err)
+				}
+			}
+			for _, op := range tt.setup {
+				if ok, _, err := m.kvApplyAt(op, t0); !ok || err != nil {
+					t.Fatalf("setup %s: ok=%v err=%v", op.Verb, ok, err)
+				}
+			}
+			before := m.index
+			ok, idx, err := m.kvApplyAt(tt.op, t0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok && m.index != before {
+				t.Fatalf("failed op advanced index %d -> %d", before, m.index)
+			}
+			if err == nil && idx != m.index {
+				t.Fatalf("returned idx = %d, want %d", idx, m.index)
+			}
+			if tt.check != nil {
+				e, _, _ := m.KVGet(context.Background(), tt.op.Key)
+				tt.check(t, e)
+			}
+		})
+	}
+}
+
+func TestKVDeleteTree(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0)
+	for _, k := range []string{"app/a", "app/b/c", "apple", "other"} {
+		m.kvApplyAt(KVOp{Verb: KVSet, Key: k, Value: []byte("v")}, t0)
+	}
+	ctx := context.Background()
+	_, tree, _ := m.KVList(ctx, "app/")
+	if ok, _, err := m.kvApplyAt(KVOp{Verb: KVDeleteTree, Key: "app/"}, t0); !ok || err != nil {
+		t.Fatalf("delete tree: ok=%v err=%v", ok, err)
+	}
+	if got, _, _ := m.KVList(ctx, "app/"); len(got) != 0 {
+		t.Fatalf("entries left under prefix: %v", got)
+	}
+	if got, _, _ := m.KVList(ctx, "app"); len(got) != 1 || got[0].Key != "apple" {
+		t.Fatalf("sibling keys = %v, want [apple]", got)
+	}
+	if _, idx, _ := m.KVList(ctx, "app/"); idx <= tree {
+		t.Fatalf("tree index after delete = %d, want > %d", idx, tree)
+	}
+}
+
+func TestTombstoneWatermark(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	var g tombstoneGC
+	for i := 0; i <= 20; i++ {
+		now := t0.Add(time.Duration(i) * time.Minute)
+		w := g.watermark(now, uint64(100+i))
+		var want uint64
+		if i >= 15 {
+			want = uint64(100 + i - 15) // KVTombstoneTTL 之前的索引
+		}
+		if w != want {
+			t.Fatalf("minute %d: watermark = %d, want %d", i, w, want)
+		}
+	}
+	if len(g.samples) != 16 {
+		t.Fatalf("samples = %d, want 16 (older samples dropped)", len(g.samples))
+	}
+	// 距上次回收不足 KVTombstoneGCInterval 时不返回水位
+	if w := g.watermark(t0.Add(20*time.Minute+time.Second), 200); w != 0 {
+		t.Fatalf("watermark within gc interval = %d, want 0", w)
+	}
+}
+
+func TestReapTombstonesKeepsIndexMonotonic(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0)
+	for _, k := range []string{"app/a", "app/b", "other/c"} {
+		if _, _, err := m.kvApplyAt(KVOp{Verb: KVSet, Key: k, Value: []byte("v")}, t0); err != nil {
+			t.Fatalf("set %s: %v", k, err)
+		}
+	}
+	m.kvApplyAt(KVOp{Verb: KVDelete, Key: "app/a"}, t0)
+	delA := m.index
+	m.kvApplyAt(KVOp{Verb: KVDelete, Key: "other/c"}, t0)
+	delC := m.index
+
+	ctx := context.Background()
+	_, beforeKey, _ := m.KVGet(ctx, "app/a")
+	_, beforeTree, _ := m.KVList(ctx, "app/")
+	if beforeKey != delA || beforeTree != delA {
+		t.Fatalf("before reap: key idx=%d tree idx=%d, want %d", beforeKey, beforeTree, delA)
+	}
+
+	m.reapTombstones(delA)
+	if _, ok := m.kvTombstones["app/a"]; ok {
+		t.Fatalf("tombstone at watermark not reaped")
+	}
+	if _, ok := m.kvTombstones["other/c"]; !ok {
+		t.Fatalf("tombstone above watermark reaped")
+	}
+	_, afterKey, _ := m.KVGet(ctx, "app/a")
+	_, afterTree, _ := m.KVList(ctx, "app/")
+	if afterKey < beforeKey || afterTree < beforeTree {
+		t.Fatalf("index went backwards after reap: key %d->%d tree %d->%d", beforeKey, afterKey, beforeTree, afterTree)
+	}
+	if _, idx, _ := m.KVGet(ctx, "other/c"); idx != delC {
+		t.Fatalf("unreaped tombstone idx = %d, want %d", idx, delC)
+	}
+
+	// 回收后阻塞查询以下限索引等待，不会立即返回
+	curr, ch := m.KVWatch(ctx, "app/a", false, afterKey)
+	select {
+	case <-ch:
+		t.Fatalf("watch fired at idx %d without change", curr)
+	default:
+	}
+	m.kvApplyAt(KVOp{Verb: KVSet, Key: "app/a", Value: []byte("v2")}, t0)
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("watch did not fire after write")
+	}
+}
+
+func TestExpirerReapsOldTombstones(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0)
+	m.kvApplyAt(KVOp{Verb: KVSet, Key: "k", Value: []byte("v")}, t0)
+	m.kvApplyAt(KVOp{Verb: KVDelete, Key: "k"}, t0)
+
+	m.mu.RLock()
+	w := m.tombstoneWatermarkLocked(t0)
+	m.mu.RUnlock()
+	if w != 0 {
+		t.Fatalf("fresh tombstone watermark = %d, want 0", w)
+	}
+	m.mu.RLock()
+	w = m.tombstoneWatermarkLocked(t0.Add(KVTombstoneTTL + time.Second))
+	m.mu.RUnlock()
+	if w != m.index {
+		t.Fatalf("watermark after ttl = %d, want %d", w, m.index)
+	}
+	m.reapTombstones(w)
+	if len(m.kvTombstones) != 0 || m.kvReapIndex != w {
+		t.Fatalf("tombstones = %v, reap index = %d", m.kvTombstones, m.kvReapIndex)
+	}
+}
@@ -0,0 +1,175 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// memnamespace.go - memoryRegistry 的命名空间管理
+// 命名空间可显式创建并设置配额；严格模式（NamespaceConfig.Strict，经 Raft 复制）下
+// 拒绝注册到未创建的命名空间（default 始终可用）。
+// 配额在注册新实例时检查，已存在实例的重新注册不受影响。
+
+// nsOrDefault 将空命名空间归一为 DefaultNamespace。
+func nsOrDefault(ns string) string {
+	if ns == "" {
+		return DefaultNamespace
+	}
+	return ns
+}
+
+func (m *memoryRegistry) UpsertNamespace(ctx context.Context, ns Namespace) (uint64, error) {
+	if ns.Name == "" {
+		return 0, errors.New("missing namespace Name")
+	}
+	if ns.Quota.MaxInstances < 0 || ns.Quota.MaxServices < 0 {
+		return 0, errors.New("quota must be >= 0")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.index++
+	if old, ok := m.namespaces[ns.Name]; ok {
+		ns.CreateIndex = old.CreateIndex
+	} else {
+		ns.CreateIndex = m.index
+	}
+	ns.ModifyIndex = m.index
+	m.namespaces[ns.Name] = &ns
+	return m.index, nil
+}
+
+// DeleteNamespace 删除显式命名空间；命名空间下仍有实例或配置对象时拒绝删除，
+// 避免遗留对象在同名命名空间重建后重新生效。
+func (m *memoryRegistry) DeleteNamespace(ctx context.Context, name string) (uint64, error) {
+	if name == DefaultNamespace {
+		return 0, errors.New("default namespace cannot be deleted")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.namespaces[name]; !ok {
+		return m.index, errors.New("namespace not found")
+	}
+	if len(m.nsServices[name]) > 0 {
+		return m.index, errors.New("namespace not empty")
+	}
+	if kind := m.namespaceOwnerLocked(name); kind != "" {
+		return m.index, fmt.Errorf("namespace not empty: %s remain", kind)
+	}
+	delete(m.namespaces, name)
+	m.index++
+	return m.index, nil
+}
+
+// ListNamespaces 列出显式创建的命名空间、default 以及因注册实例而隐式存在的命名空间。
+func (m *memoryRegistry) ListNamespaces(ctx context.Context) ([]NamespaceView, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := map[string]struct{}{DefaultNamespace: {}}
+	for name := range m.namespaces {
+		names[name] = struct{}{}
+	}
+	for name := range m.nsServices {
+		names[name] = struct{}{}
+	}
+	out := make([]NamespaceView, 0, len(names))
+	for name := range names {
+		out = append(out, m.namespaceViewLocked(name))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, m.index, nil
+}
+
+func (m *memoryRegistry) GetNamespace(ctx context.Context, name string) (NamespaceView, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, explicit := m.namespaces[name]
+	if !explicit && name != DefaultNamespace && len(m.nsServices[name]) == 0 {
+		return NamespaceView{}, m.index, errors.New("namespace not found")
+	}
+	return m.namespaceViewLocked(name), m.index, nil
+}
+
+// SetNamespaceConfig 更新命名空间的全局配置。
+func (m *memoryRegistry) SetNamespaceConfig(ctx context.Context, c NamespaceConfig) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.index++
+	c.ModifyIndex = m.index
+	m.namespaceConfig = c
+	return m.index, nil
+}
+
+func (m *memoryRegistry) GetNamespaceConfig(ctx context.Context) (NamespaceConfig, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.namespaceConfig, m.index, nil
+}
+
+// --- 内部方法 ---
+
+func (m *memoryRegistry) namespaceViewLocked(name string) NamespaceView {
+	view := NamespaceView{Namespace: Namespace{Name: name}}
+	if ns, ok := m.namespaces[name]; ok {
+		view.Namespace = *ns
+	} else {
+		view.Implicit = name != DefaultNamespace
+	}
+	view.Services, view.Instances = m.namespaceUsageLocked(name)
+	return view
+}
+
+// namespaceUsageLocked 返回命名空间下的服务数与实例数。
+func (m *memoryRegistry) namespaceUsageLocked(name string) (services, instances int) {
+	for svc := range m.nsServices[name] {
+		instances += len(m.svcInstances[m.svcKey(name, svc)])
+	}
+	return len(m.nsServices[name]), instances
+}
+
+// namespaceOwnerLocked 返回命名空间下仍存在的第一类配置对象（服务配置、流量拆分、预设查询、调用规则），无则返回空串。
+func (m *memoryRegistry) namespaceOwnerLocked(name string) string {
+	for _, d := range m.serviceDefaults {
+		if d.Namespace == name {
+			return "service defaults"
+		}
+	}
+	for _, sp := range m.serviceSplitters {
+		if sp.Namespace == name {
+			return "service splitters"
+		}
+	}
+	for _, q := range m.queries {
+		if q.Namespace == name {
+			return "prepared queries"
+		}
+	}
+	for _, it := range m.intentions {
+		if it.SourceNS == name || it.DestinationNS == name {
+			return "intentions"
+		}
+	}
+	return ""
+}
+
+// admitInstanceLocked 检查新实例能否注册到命名空间：严格模式下命名空间必须已创建，且不得超出配额。
+func (m *memoryRegistry) admitInstanceLocked(inst ServiceInstance) error {
+	ns, ok := m.namespaces[inst.Namespace]
+	if !ok {
+		if m.namespaceConfig.Strict && inst.Namespace != DefaultNamespace {
+			return fmt.Errorf("namespace not found: %s", inst.Namespace)
+		}
+		return nil
+	}
+	services, instances := m.namespaceUsageLocked(inst.Namespace)
+	if q := ns.Quota.MaxInstances; q > 0 && instances >= q {
+		return fmt.Errorf("namespace %s quota exceeded: max %d instances", inst.Namespace, q)
+	}
+	if _, exists := m.nsServices[inst.Namespace][inst.Service]; !exists {
+		if q := ns.Quota.MaxServices; q > 0 && services >= q {
+			return fmt.Errorf("namespace %s quota exceeded: max %d services", inst.Namespace, q)
+		}
+	}
+	return nil
+}
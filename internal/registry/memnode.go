@@ -0,0 +1,157 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// memnode.go - memoryRegistry 的节点管理
+// 节点代表运行 Agent 的主机，可携带节点级检查；节点检查的聚合状态会叠加到该节点上所有实例的健康状态。
+
+type nodeRecord struct {
+	node   Node
+	checks []string // 节点检查 ID 列表
+}
+
+// nodeCheckID 生成节点第 i 个检查的 ID。
+func nodeCheckID(name string, i int) string {
+	return "nodechk:" + name + ":" + itoa(i)
+}
+
+func (m *memoryRegistry) RegisterNode(ctx context.Context, node Node, specs []CheckSpec) (uint64, []string, error) {
+	return m.registerNodeAt(node, specs, time.Now())
+}
+
+// registerNodeAt 注册或更新节点；节点检查按位置调和，配置未变的检查保留状态。
+func (m *memoryRegistry) registerNodeAt(node Node, specs []CheckSpec, now time.Time) (uint64, []string, error) {
+	if node.Name == "" {
+		return 0, nil, errors.New("missing node Name")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.nodes[node.Name]
+	if !ok {
+		rec = &nodeRecord{}
+		m.nodes[node.Name] = rec
+	}
+	rec.node = node
+
+	keep := make(map[string]bool, len(specs))
+	checkIDs := make([]string, 0, len(specs))
+	for i, s := range specs {
+		cid := nodeCheckID(node.Name, i)
+		keep[cid] = true
+		checkIDs = append(checkIDs, cid)
+		m.checkNode[cid] = node.Name
+		if cr, ok := m.checks[cid]; ok && cr.chk.Spec == normalizeSpec(s) {
+			continue
+		}
+		m.putCheckLocked(cid, newCheck(cid, s, now))
+	}
+	for _, cid := range rec.checks {
+		if !keep[cid] {
+			m.deleteCheckLocked(cid)
+			delete(m.checkNode, cid)
+		}
+	}
+	rec.checks = checkIDs
+
+	idx := m.advanceLocked(m.servicesOfNodeLocked(node.Name))
+	m.invalidateSessionsLocked(now)
+	return idx, append([]string(nil), checkIDs...), nil
+}
+
+func (m *memoryRegistry) DeregisterNode(ctx context.Context, name string) (uint64, error) {
+	return m.deregisterNodeAt(name, time.Now())
+}
+
+// deregisterNodeAt 注销节点；时间用于关联会话失效时的 lock-delay。
+func (m *memoryRegistry) deregisterNodeAt(name string, now time.Time) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.nodes[name]
+	if !ok {
+		return m.index, errors.New("node not found")
+	}
+	// 仅移除节点及其检查；节点上的实例保持注册，由各自 Agent 负责注销
+	for _, cid := range rec.checks {
+		m.deleteCheckLocked(cid)
+		delete(m.checkNode, cid)
+	}
+	delete(m.nodes, name)
+	idx := m.advanceLocked(m.servicesOfNodeLocked(name))
+	m.invalidateSessionsLocked(now)
+	return idx, nil
+}
+
+func (m *memoryRegistry) ListNodes(ctx context.Context) ([]NodeView, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]NodeView, 0, len(m.nodes))
+	for _, rec := range m.nodes {
+		out = append(out, m.nodeViewLocked(rec))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, m.index, nil
+}
+
+func (m *memoryRegistry) GetNode(ctx context.Context, name string) (NodeView, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rec, ok := m.nodes[name]
+	if !ok {
+		return NodeView{}, m.index, errors.New("node not found")
+	}
+	view := m.nodeViewLocked(rec)
+	for k := range m.nodeInstances[name] {
+		if inst, ok := m.instances[k]; ok {
+			view.Instances = append(view.Instances, m.instanceViewLocked(inst))
+		}
+	}
+	sort.Slice(view.Instances, func(i, j int) bool {
+		a, b := view.Instances[i], view.Instances[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.ID < b.ID
+	})
+	return view, m.index, nil
+}
+
+// --- 内部方法 ---
+
+func (m *memoryRegistry) nodeViewLocked(rec *nodeRecord) NodeView {
+	return NodeView{
+		Name:    rec.node.Name,
+		Address: rec.node.Address,
+		Meta:    cloneMap(rec.node.Meta),
+		Status:  m.checksStatusLocked(rec.checks).String(),
+	}
+}
+
+// nodeStatusLocked 返回节点检查的聚合状态；未注册的节点不影响实例健康（视为 passing）。
+func (m *memoryRegistry) nodeStatusLocked(name string) CheckStatus {
+	rec, ok := m.nodes[name]
+	if !ok {
+		return StatusPassing
+	}
+	return m.checksStatusLocked(rec.checks)
+}
+
+// servicesOfNodeLocked 返回节点上所有实例所属的服务键（已排序）。
+func (m *memoryRegistry) servicesOfNodeLocked(name string) []string {
+	var svcs []string
+	for k := range m.nodeInstances[name] {
+		if rec, ok := m.instances[k]; ok {
+			svcs = appendUnique(svcs, m.svcKey(rec.inst.Namespace, rec.inst.Service))
+		}
+	}
+	sort.Strings(svcs)
+	return svcs
+}
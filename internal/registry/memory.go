@@ -2,9 +2,12 @@ package registry
 
 import (
 	"context"
+	crand "crypto/rand"
 	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 )
@@ -23,18 +26,81 @@ type memoryRegistry struct {
 	// id -> 完整键（ns/svc/id）。ID 通常全局唯一，但仍保留映射以兼容查询。
 	idToKeys map[string][]string
 
+	// 二级索引（见 memindex.go）
+	svcInstances  map[string]map[string]*instanceRecord // 服务键 -> 实例键 -> 实例
+	nsServices    map[string]map[string]struct{}        // 命名空间 -> 服务名集合
+	checkOwner    map[string]string                     // checkID -> 实例键
+	nodeInstances map[string]map[string]struct{}        // 节点名 -> 实例键集合
+	checkNode     map[string]string                     // 节点检查 ID -> 节点名
+
+	// 节点名 -> 节点（见 memnode.go）
+	nodes map[string]*nodeRecord
+
+	// 显式创建的命名空间；namespaceConfig.Strict 为真时拒绝注册到未创建的命名空间（见 memnamespace.go）
+	namespaces      map[string]*Namespace
+	namespaceConfig NamespaceConfig
+
+	// 键值存储：键 -> 条目、已删除键 -> 删除时索引、已回收墓碑的最大索引、阻塞查询 Watchers（见 memkv.go）
+	kv           map[string]*KVEntry
+	kvTombstones map[string]uint64
+	kvReapIndex  uint64
+	kvWatchers   []kvWatcher
+	tombGC       tombstoneGC
+
+	// 会话 ID -> 会话、键 -> lock-delay 截止时间（见 memsession.go）
+	sessions   map[string]*Session
+	lockDelays map[string]time.Time
+
+	// 截止时间索引：TTL 检查过期、critical 超时注销、会话过期（见 memindex.go）
+	ttlDeadlines     deadlineIndex
+	reapDeadlines    deadlineIndex
+	sessionDeadlines deadlineIndex
+
+	// 检查 ID -> 关联会话；staleChecks 为已删除或变为 critical、尚待使关联会话失效的检查
+	checkSessions map[string]map[string]struct{}
+	staleChecks   map[string]struct{}
+
+	// 查询名 -> 预设查询（见 memquery.go）
+	queries map[string]*PreparedQuery
+
+	// 服务键 -> 服务级配置项（见 memconfig.go）
+	serviceDefaults map[string]*ServiceDefaults
+
+	// 规则 ID -> 服务间调用规则；intentionConfig 含无匹配规则时的默认策略（见 memintention.go）
+	intentions      map[string]*Intention
+	intentionConfig IntentionConfig
+
+	// 服务键 -> 子集定义与流量拆分（见 memsplitter.go）
+	serviceSplitters map[string]*ServiceSplitter
+
 	// 服务键 -> 该服务最新索引
 	svcIndex map[string]uint64
 
 	// 服务键 -> Watchers 列表
 	watchers map[string][]chan struct{}
 
+	// 健康索引与 Watchers：任一服务索引推进（含检查状态变化）或节点检查变化时推进，
+	// 供跨服务的检查查询长轮询（见 memcheck.go）
+	healthIndex    uint64
+	healthWatchers []chan struct{}
+
+	// 命名空间级索引与 Watchers：仅在实例增删改（目录变化）时推进，健康状态变化不影响
+	nsIndex    map[string]uint64
+	nsWatchers map[string][]chan struct{}
+
 	// 全局索引
 	index uint64
 
+	// Raft 节点 ID -> HTTP 地址（集群元数据，用于将写请求转发给 Leader）
+	servers map[string]string
+
 	// 过期清理的后台通道与状态
 	stopCh         chan struct{}
 	expirerStarted bool
+
+	// 抖动检测：窗口内状态切换次数超过阈值即视为抖动（见 history.go）
+	flapThreshold int
+	flapWindow    time.Duration
 }
 
 type instanceRecord struct {
@@ -50,6 +116,11 @@ type checkRecord struct {
 type Options struct {
 	// AutoExpirer: 是否在创建时自动启动 TTL 过期清理器。
 	AutoExpirer bool
+
+	// FlapThreshold/FlapWindow: 检查在窗口内状态切换超过 FlapThreshold 次即标记为抖动；
+	// 为 0 时使用 DefaultFlapThreshold/DefaultFlapWindow。
+	FlapThreshold int
+	FlapWindow    time.Duration
 }
 
 func NewMemoryRegistry() *memoryRegistry { // 兼容旧接口，默认自动启用过期器
@@ -64,6 +135,45 @@ func NewMemoryRegistryWithOptions(opts Options) *memoryRegistry {
 		idToKeys:  make(map[string][]string),
 		svcIndex:  make(map[string]uint64),
 		watchers:  make(map[string][]chan struct{}),
+		servers:   make(map[string]string),
+
+		nsIndex:    make(map[string]uint64),
+		nsWatchers: make(map[string][]chan struct{}),
+
+		svcInstances: make(map[string]map[string]*instanceRecord),
+		nsServices:   make(map[string]map[string]struct{}),
+		checkOwner:   make(map[string]string),
+
+		nodes:         make(map[string]*nodeRecord),
+		nodeInstances: make(map[string]map[string]struct{}),
+		checkNode:     make(map[string]string),
+
+		flapThreshold: opts.FlapThreshold,
+		flapWindow:    opts.FlapWindow,
+
+		namespaces: make(map[string]*Namespace),
+
+		kv:           make(map[string]*KVEntry),
+		kvTombstones: make(map[string]uint64),
+
+		sessions:   make(map[string]*Session),
+		lockDelays: make(map[string]time.Time),
+
+		checkSessions: make(map[string]map[string]struct{}),
+		staleChecks:   make(map[string]struct{}),
+
+		queries:         make(map[string]*PreparedQuery),
+		serviceDefaults: make(map[string]*ServiceDefaults),
+
+		intentions: make(map[string]*Intention),
+
+		serviceSplitters: make(map[string]*ServiceSplitter),
+	}
+	if mr.flapThreshold <= 0 {
+		mr.flapThreshold = DefaultFlapThreshold
+	}
+	if mr.flapWindow <= 0 {
+		mr.flapWindow = DefaultFlapWindow
 	}
 	if opts.AutoExpirer {
 		mr.StartExpirer()
@@ -94,28 +204,85 @@ func (m *memoryRegistry) nextIndexLocked(svc string) uint64 {
 		}
 		m.watchers[svc] = nil
 	}
+	m.touchHealthLocked()
 	return m.index
 }
 
+// closeWatchersLocked 关闭所有服务、命名空间、健康与键值 Watchers 的通道并清空列表（快照恢复时使用）。
+func (m *memoryRegistry) closeWatchersLocked() {
+	for _, lst := range m.watchers {
+		for _, ch := range lst {
+			close(ch)
+		}
+	}
+	for _, lst := range m.nsWatchers {
+		for _, ch := range lst {
+			close(ch)
+		}
+	}
+	for _, ch := range m.healthWatchers {
+		close(ch)
+	}
+	for _, w := range m.kvWatchers {
+		close(w.ch)
+	}
+	m.watchers = make(map[string][]chan struct{})
+	m.nsWatchers = make(map[string][]chan struct{})
+	m.healthWatchers = nil
+	m.kvWatchers = nil
+}
+
+// touchNamespaceLocked 将命名空间索引推进到当前全局索引，并通知其 Watchers。
+// 须在 nextIndexLocked 之后调用。
+func (m *memoryRegistry) touchNamespaceLocked(ns string) {
+	m.nsIndex[ns] = m.index
+	for _, ch := range m.nsWatchers[ns] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+		close(ch)
+	}
+	delete(m.nsWatchers, ns)
+}
+
 func (m *memoryRegistry) RegisterInstance(ctx context.Context, inst ServiceInstance, specs []CheckSpec) (uint64, []string, error) {
-	if inst.Namespace == "" || inst.Service == "" || inst.ID == "" {
-		return 0, nil, errors.New("missing Namespace/Service/ID")
+	return m.registerAt(inst, specs, time.Now())
+}
+
+// registerAt 以给定时间注册实例；Raft 回放时由 FSM 传入日志时间，保证各节点结果一致。
+func (m *memoryRegistry) registerAt(inst ServiceInstance, specs []CheckSpec, now time.Time) (uint64, []string, error) {
+	inst.Namespace = nsOrDefault(inst.Namespace)
+	if inst.Service == "" || inst.ID == "" {
+		return 0, nil, errors.New("missing Service/ID")
 	}
 	svc := m.svcKey(inst.Namespace, inst.Service)
 	k := m.key(inst.Namespace, inst.Service, inst.ID)
 
-	now := time.Now()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.instances[k]; exists {
-		// 若实例已存在：仅更新元信息；检查集合保持不变（M1 简化）。
-		rec := m.instances[k]
+	// 合并服务级配置项（实例自身的配置优先）
+	inst, specs = m.applyServiceDefaultsLocked(inst, specs)
+
+	if rec, exists := m.instances[k]; exists {
+		// 若实例已存在：更新元信息，并按请求的检查列表调和现有检查。
 		inst.CreateIndex = rec.inst.CreateIndex
 		inst.ModifyIndex = m.index + 1
+		// 所属节点可能变化：先移出索引，更新后重新加入
+		m.unindexInstanceLocked(k, rec)
 		rec.inst = inst
+		checkIDs := m.reconcileChecksLocked(k, rec.checks, specs, now)
+		m.indexInstanceLocked(k, rec)
 		idx := m.nextIndexLocked(svc)
-		return idx, nil, nil
+		m.touchNamespaceLocked(inst.Namespace)
+		// 被删除或重置为 critical 的检查会使关联会话失效
+		m.invalidateSessionsLocked(now)
+		return idx, checkIDs, nil
+	}
+
+	if err := m.admitInstanceLocked(inst); err != nil {
+		return m.index, nil, err
 	}
 
 	inst.CreateIndex = m.index + 1
@@ -125,28 +292,59 @@ func (m *memoryRegistry) RegisterInstance(ctx context.Context, inst ServiceInsta
 	// 创建健康检查
 	var checkIDs []string
 	for i, s := range specs {
-		cid := "chk:" + inst.ID + ":" + itoa(i)
-		spec := s
-		// 归一化时长：若上游解析失败则为 0（等同未启用）。
-		// TTL 检查在首次续约前标记为 critical。
-		chk := Check{ID: cid, Spec: spec, Status: StatusUnknown, LastUpdate: now}
-		if spec.Type == CheckTTL {
-			// 尚未续约
-			chk.Status = StatusCritical
-		}
-		m.checks[cid] = &checkRecord{chk: chk}
+		cid := checkID(inst.ID, i)
+		m.putCheckLocked(cid, newCheck(cid, s, now))
 		rec.checks = append(rec.checks, cid)
 		checkIDs = append(checkIDs, cid)
 	}
 
 	m.instances[k] = rec
-	m.idToKeys[inst.ID] = append(m.idToKeys[inst.ID], k)
+	m.indexInstanceLocked(k, rec)
 
 	idx := m.nextIndexLocked(svc)
+	m.touchNamespaceLocked(inst.Namespace)
 	return idx, checkIDs, nil
 }
 
+// reconcileChecksLocked 将实例的现有检查调和为 specs 描述的集合，返回新的有序检查 ID 列表。
+// 检查按位置对应（chk:{id}:{i}）：配置未变的检查保留状态；配置变化的检查重置状态；
+// 新增位置创建检查；多出的旧检查被删除。维护模式的合成检查不受影响，也不出现在返回列表中。
+func (m *memoryRegistry) reconcileChecksLocked(k string, old []string, specs []CheckSpec, now time.Time) []string {
+	rec := m.instances[k]
+	keep := make(map[string]bool, len(specs))
+	checkIDs := make([]string, 0, len(specs))
+	for i, s := range specs {
+		cid := checkID(rec.inst.ID, i)
+		keep[cid] = true
+		checkIDs = append(checkIDs, cid)
+		m.checkOwner[cid] = k
+		if cr, ok := m.checks[cid]; ok && cr.chk.Spec == normalizeSpec(s) {
+			continue
+		}
+		m.putCheckLocked(cid, newCheck(cid, s, now))
+	}
+	all := append([]string(nil), checkIDs...)
+	for _, cid := range old {
+		if keep[cid] {
+			continue
+		}
+		if cr, ok := m.checks[cid]; ok && cr.chk.Spec.Type == CheckMaint {
+			all = append(all, cid)
+			continue
+		}
+		m.deleteCheckLocked(cid)
+		delete(m.checkOwner, cid)
+	}
+	rec.checks = all
+	return checkIDs
+}
+
 func (m *memoryRegistry) DeregisterInstance(ctx context.Context, namespace, service, id string) (uint64, error) {
+	return m.deregisterAt(namespace, service, id, time.Now())
+}
+
+// deregisterAt 注销实例；时间用于关联会话失效时的 lock-delay。
+func (m *memoryRegistry) deregisterAt(namespace, service, id string, now time.Time) (uint64, error) {
 	if id == "" {
 		return 0, errors.New("missing id")
 	}
@@ -163,6 +361,8 @@ func (m *memoryRegistry) DeregisterInstance(ctx context.Context, namespace, serv
 	if len(keys) == 0 {
 		return m.index, errors.New("instance not found")
 	}
+	changedSvc := []string{}
+	var changedNs []string
 	for _, k := range keys {
 		rec, ok := m.instances[k]
 		if !ok {
@@ -170,17 +370,32 @@ func (m *memoryRegistry) DeregisterInstance(ctx context.Context, namespace, serv
 		}
 		// 删除其下的所有检查
 		for _, cid := range rec.checks {
-			delete(m.checks, cid)
+			m.deleteCheckLocked(cid)
 		}
+		m.unindexInstanceLocked(k, rec)
 		delete(m.instances, k)
+		changedSvc = appendUnique(changedSvc, m.svcKey(rec.inst.Namespace, rec.inst.Service))
+		changedNs = appendUnique(changedNs, rec.inst.Namespace)
 	}
-	// 清理 id 索引
-	delete(m.idToKeys, id)
-	idx := m.nextIndexLocked(svc)
+	if len(changedSvc) == 0 {
+		changedSvc = append(changedSvc, svc)
+	}
+	var idx uint64
+	for _, s := range changedSvc {
+		idx = m.nextIndexLocked(s)
+	}
+	for _, ns := range changedNs {
+		m.touchNamespaceLocked(ns)
+	}
+	m.invalidateSessionsLocked(now)
 	return idx, nil
 }
 
 func (m *memoryRegistry) RenewTTL(ctx context.Context, checkID string) (uint64, error) {
+	return m.renewTTLAt(checkID, time.Now())
+}
+
+func (m *memoryRegistry) renewTTLAt(checkID string, now time.Time) (uint64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	cr, ok := m.checks[checkID]
@@ -190,97 +405,209 @@ func (m *memoryRegistry) RenewTTL(ctx context.Context, checkID string) (uint64,
 	if cr.chk.Spec.Type != CheckTTL {
 		return m.index, errors.New("not a ttl check")
 	}
-	cr.chk.Status = StatusPassing
-	cr.chk.LastPass = time.Now()
-	cr.chk.LastUpdate = cr.chk.LastPass
+	// 续约总是刷新 LastPass（避免 TTL 过期），状态切换仍受 SuccessBeforePassing 约束
+	cr.chk.applyResult(StatusPassing, now)
+	cr.chk.LastPass = now
+	m.touchCheckLocked(checkID)
 
-	// 找到所属服务，发送通知
-	svc := m.findSvcKeyByCheckLocked(checkID)
-	idx := m.nextIndexLocked(svc)
+	// 找到受影响的服务，发送通知
+	idx := m.advanceLocked(m.servicesForCheckLocked(checkID))
 	return idx, nil
 }
 
-func (m *memoryRegistry) ReportCheck(ctx context.Context, checkID string, status CheckStatus, output string) (uint64, error) {
+func (m *memoryRegistry) ReportCheck(ctx context.Context, checkID string, status CheckStatus, output, note string) (uint64, error) {
+	return m.reportCheckAt(checkID, status, output, note, time.Now())
+}
+
+func (m *memoryRegistry) reportCheckAt(checkID string, status CheckStatus, output, note string, now time.Time) (uint64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	cr, ok := m.checks[checkID]
 	if !ok {
 		return m.index, errors.New("check not found")
 	}
-	cr.chk.Status = status
+	if cr.chk.Spec.Type == CheckMaint {
+		return m.index, errors.New("maintenance check cannot be updated")
+	}
+	if cr.chk.applyResult(status, now) {
+		// 状态切换记录附带本次输出，便于事后排查
+		cr.chk.History[len(cr.chk.History)-1].Output = output
+	}
+	if status == StatusPassing && cr.chk.Spec.Type == CheckTTL {
+		// 带输出的 pass 上报同样视为 TTL 续约
+		cr.chk.LastPass = now
+	}
 	cr.chk.Output = output
-	cr.chk.LastUpdate = time.Now()
-	svc := m.findSvcKeyByCheckLocked(checkID)
-	idx := m.nextIndexLocked(svc)
+	cr.chk.Note = note
+	m.touchCheckLocked(checkID)
+	idx := m.advanceLocked(m.servicesForCheckLocked(checkID))
+	m.invalidateSessionsLocked(now)
 	return idx, nil
 }
 
+func (m *memoryRegistry) SetMaintenance(ctx context.Context, namespace, service, id string, enable bool, reason string) (uint64, error) {
+	return m.setMaintenanceAt(namespace, service, id, enable, reason, time.Now())
+}
+
+// setMaintenanceAt 开启/关闭维护模式：id 非空时作用于单个实例，否则作用于 namespace/service 下的全部实例。
+// 开启时为实例注入一个 critical 的合成检查，使其被 PassingOnly 查询排除但仍保留注册。
+func (m *memoryRegistry) setMaintenanceAt(namespace, service, id string, enable bool, reason string, now time.Time) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 按服务定位时命名空间缺省为 default，与注册时一致
+	if service != "" {
+		namespace = nsOrDefault(namespace)
+	}
+	var keys []string
+	switch {
+	case id != "" && namespace != "" && service != "":
+		keys = []string{m.key(namespace, service, id)}
+	case id != "":
+		keys = append(keys, m.idToKeys[id]...)
+	case namespace != "" && service != "":
+		for k := range m.svcInstances[m.svcKey(namespace, service)] {
+			keys = append(keys, k)
+		}
+	default:
+		return m.index, errors.New("missing id or Namespace/Service")
+	}
+	sort.Strings(keys)
+
+	var changedSvc []string
+	found := false
+	for _, k := range keys {
+		rec, ok := m.instances[k]
+		if !ok {
+			continue
+		}
+		found = true
+		cid := maintCheckID(rec.inst.ID)
+		_, exists := m.checks[cid]
+		switch {
+		case enable:
+			chk := Check{ID: cid, Spec: CheckSpec{Type: CheckMaint}, Output: reason}
+			chk.forceStatus(StatusCritical, now)
+			if exists {
+				// 重复开启仅更新原因，保留进入维护的时间
+				chk = m.checks[cid].chk
+				chk.Output = reason
+				chk.LastUpdate = now
+			} else {
+				rec.checks = append(rec.checks, cid)
+				m.checkOwner[cid] = k
+			}
+			m.putCheckLocked(cid, chk)
+		case exists:
+			m.deleteCheckLocked(cid)
+			delete(m.checkOwner, cid)
+			rec.checks = removeString(rec.checks, cid)
+		default:
+			continue
+		}
+		changedSvc = appendUnique(changedSvc, m.svcKey(rec.inst.Namespace, rec.inst.Service))
+	}
+	if !found {
+		return m.index, errors.New("instance not found")
+	}
+	for _, svc := range changedSvc {
+		m.nextIndexLocked(svc)
+	}
+	return m.index, nil
+}
+
 func (m *memoryRegistry) ListHealthyInstances(ctx context.Context, namespace, service string, opts ListOptions) ([]InstanceView, uint64, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	out, idx := m.listHealthyInstancesLocked(namespace, service, opts)
+	return out, idx, nil
+}
 
+// listHealthyInstancesLocked 按 opts 筛选并排序服务实例，返回实例视图与服务索引。调用方需持有读锁。
+func (m *memoryRegistry) listHealthyInstancesLocked(namespace, service string, opts ListOptions) ([]InstanceView, uint64) {
 	var out []InstanceView
-	svc := m.svcKey(namespace, service)
-	prefix := namespace + "/" + service + "/"
-	for k, rec := range m.instances {
-		if !strings.HasPrefix(k, prefix) {
+	svc := m.svcKey(nsOrDefault(namespace), service)
+	localPassing := false // near 模式下本地 zone 是否存在 passing 实例
+	for _, rec := range m.svcInstances[svc] {
+		zone := instanceZone(rec.inst)
+		if opts.Zone != "" && zone != opts.Zone {
 			continue
 		}
-		if opts.PassingOnly {
-			if aggStatus := m.aggregateStatusLocked(rec); aggStatus != StatusPassing {
-				continue
-			}
+		if opts.Tag != "" && !hasString(rec.inst.Tags, opts.Tag) {
+			continue
 		}
-		out = append(out, InstanceView{
-			Namespace: rec.inst.Namespace,
-			Service:   rec.inst.Service,
-			ID:        rec.inst.ID,
-			Address:   rec.inst.Address,
-			Port:      rec.inst.Port,
-			Tags:      append([]string(nil), rec.inst.Tags...),
-			Meta:      cloneMap(rec.inst.Meta),
-			Weights:   rec.inst.Weights,
-		})
+		aggStatus := m.aggregateStatusLocked(rec)
+		if opts.PassingOnly && aggStatus != StatusPassing {
+			continue
+		}
+		view := m.instanceViewLocked(rec)
+		view.status = aggStatus
+		if !opts.Filter.Match(view) {
+			continue
+		}
+		if opts.Near != "" && zone == opts.Near && aggStatus == StatusPassing {
+			localPassing = true
+		}
+		out = append(out, view)
 	}
 	// 稳定排序，确保输出一致
 	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
 
+	// 加权随机：按各实例聚合状态对应的权重打乱顺序，便于只取前 N 个的客户端按比例分摊负载。
+	if opts.Order == OrderWeighted {
+		weightedShuffle(out)
+	}
+
+	// 就近模式：本地 zone 有 passing 实例时只返回本地 zone；否则回退到全部 zone，本地实例排在前面。
+	if opts.Near != "" {
+		out = orderByLocality(out, opts.Near, localPassing)
+	}
+
 	idx := m.svcIndex[svc]
 	if idx == 0 {
 		idx = m.index
 	}
-	return out, idx, nil
+	return out, idx
 }
 
 func (m *memoryRegistry) ListServices(ctx context.Context, namespace string) ([]string, uint64, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	seen := make(map[string]struct{})
-	var names []string
-	prefix := namespace + "/"
-	for k := range m.instances {
-		if !strings.HasPrefix(k, prefix) {
-			continue
-		}
-		rest := strings.TrimPrefix(k, prefix)
-		parts := strings.SplitN(rest, "/", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		name := parts[0]
-		if _, ok := seen[name]; !ok {
-			seen[name] = struct{}{}
-			names = append(names, name)
-		}
+	ns := nsOrDefault(namespace)
+	names := []string{}
+	for name := range m.nsServices[ns] {
+		names = append(names, name)
 	}
 	sort.Strings(names)
-	return names, m.index, nil
+	// 命名空间尚无索引（不存在或从未有实例）时返回全局索引，且至少为 1，
+	// 避免客户端拿到 0 后无法发起阻塞查询而反复轮询
+	idx := m.nsIndex[ns]
+	if idx == 0 {
+		idx = max(m.index, 1)
+	}
+	return names, idx, nil
+}
+
+// WatchNamespace 监听命名空间的目录变化（实例增删改）；若 lastIndex 落后，会立刻触发一次通知。
+func (m *memoryRegistry) WatchNamespace(ctx context.Context, namespace string, lastIndex uint64) (uint64, <-chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ns := nsOrDefault(namespace)
+	curr := m.nsIndex[ns]
+	ch := make(chan struct{}, 1)
+	if curr > lastIndex {
+		ch <- struct{}{}
+		close(ch)
+		return curr, ch
+	}
+	m.nsWatchers[ns] = append(m.nsWatchers[ns], ch)
+	return curr, ch
 }
 
 func (m *memoryRegistry) WatchService(ctx context.Context, namespace, service string, lastIndex uint64) (uint64, <-chan struct{}) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	svc := m.svcKey(namespace, service)
+	svc := m.svcKey(nsOrDefault(namespace), service)
 	curr := m.svcIndex[svc]
 	ch := make(chan struct{}, 1)
 	if curr > lastIndex {
@@ -294,47 +621,116 @@ func (m *memoryRegistry) WatchService(ctx context.Context, namespace, service st
 
 // --- 内部方法 ---
 
+// setServerAddr 记录 Raft 节点的 HTTP 地址；不推进服务索引。
+func (m *memoryRegistry) setServerAddr(id, httpAddr string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if httpAddr == "" {
+		delete(m.servers, id)
+	} else {
+		m.servers[id] = httpAddr
+	}
+	return m.index
+}
+
+// serverAddr 返回 Raft 节点的 HTTP 地址（未知时为空）。
+func (m *memoryRegistry) serverAddr(id string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.servers[id]
+}
+
+// instanceViewLocked 构造实例的对外视图（不含聚合状态）。
+func (m *memoryRegistry) instanceViewLocked(rec *instanceRecord) InstanceView {
+	return InstanceView{
+		Namespace: rec.inst.Namespace,
+		Service:   rec.inst.Service,
+		ID:        rec.inst.ID,
+		Address:   rec.inst.Address,
+		Port:      rec.inst.Port,
+		Node:      rec.inst.Node,
+		Zone:      instanceZone(rec.inst),
+		Tags:      append([]string(nil), rec.inst.Tags...),
+		Meta:      cloneMap(rec.inst.Meta),
+		Weights:   rec.inst.Weights,
+	}
+}
+
+// aggregateStatusLocked 返回实例的聚合状态：实例自身检查与所属节点检查中的最坏状态。
 func (m *memoryRegistry) aggregateStatusLocked(rec *instanceRecord) CheckStatus {
-	// 聚合规则：以“最坏状态”为准；若无检查，视为 Passing。
-	if len(rec.checks) == 0 {
-		return StatusPassing
+	agg := m.checksStatusLocked(rec.checks)
+	if rec.inst.Node != "" {
+		agg = worseStatus(agg, m.nodeStatusLocked(rec.inst.Node))
 	}
+	return agg
+}
+
+func (m *memoryRegistry) checksStatusLocked(checks []string) CheckStatus {
+	// 聚合规则：以“最坏状态”为准；若无检查，视为 Passing。
 	agg := StatusPassing
-	for _, cid := range rec.checks {
+	for _, cid := range checks {
 		if cr, ok := m.checks[cid]; ok {
-			switch cr.chk.Status {
-			case StatusPassing:
-				// ok
-			case StatusWarning:
-				if agg == StatusPassing {
-					agg = StatusWarning
-				}
-			case StatusUnknown:
-				if agg == StatusPassing || agg == StatusWarning {
-					agg = StatusUnknown
-				}
-			case StatusCritical:
-				return StatusCritical
+			agg = worseStatus(agg, cr.chk.Status)
+			if agg == StatusCritical {
+				return agg
 			}
 		}
 	}
 	return agg
 }
 
-func (m *memoryRegistry) findSvcKeyByCheckLocked(checkID string) string {
-	// 遍历实例找到所属服务。时间复杂度 O(n)，对 M1 足够。
-	for k, rec := range m.instances {
-		for _, cid := range rec.checks {
-			if cid == checkID {
-				// k 形如 ns/svc/id；取前两段
-				parts := strings.Split(k, "/")
-				if len(parts) >= 2 {
-					return parts[0] + "/" + parts[1]
-				}
-			}
+// worseStatus 返回两个状态中较差者：critical > unknown > warning > passing。
+func worseStatus(a, b CheckStatus) CheckStatus {
+	rank := func(s CheckStatus) int {
+		switch s {
+		case StatusPassing:
+			return 0
+		case StatusWarning:
+			return 1
+		case StatusUnknown:
+			return 2
+		default:
+			return 3
 		}
 	}
-	return ""
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+// servicesForCheckLocked 返回检查状态变化时需推进索引的服务键：
+// 实例检查为所属服务；节点检查为该节点上所有实例的服务。
+func (m *memoryRegistry) servicesForCheckLocked(checkID string) []string {
+	if name, ok := m.checkNode[checkID]; ok {
+		return m.servicesOfNodeLocked(name)
+	}
+	if svc := m.findSvcKeyByCheckLocked(checkID); svc != "" {
+		return []string{svc}
+	}
+	return nil
+}
+
+// advanceLocked 推进给定服务的索引并返回最新全局索引；列表为空时仅推进全局索引。
+func (m *memoryRegistry) advanceLocked(svcs []string) uint64 {
+	if len(svcs) == 0 {
+		m.index++
+		m.touchHealthLocked()
+		return m.index
+	}
+	for _, svc := range svcs {
+		m.nextIndexLocked(svc)
+	}
+	return m.index
+}
+
+func (m *memoryRegistry) findSvcKeyByCheckLocked(checkID string) string {
+	// 经 checkOwner 索引定位所属实例，O(1)。
+	rec, ok := m.instances[m.checkOwner[checkID]]
+	if !ok {
+		return ""
+	}
+	return m.svcKey(rec.inst.Namespace, rec.inst.Service)
 }
 
 func (m *memoryRegistry) expirer() {
@@ -352,35 +748,236 @@ func (m *memoryRegistry) expirer() {
 
 func (m *memoryRegistry) expireOnce() {
 	now := time.Now()
+	m.mu.RLock()
+	ids := m.expiredChecksLocked(now)
+	m.mu.RUnlock()
+	if len(ids) > 0 {
+		m.expireChecksAt(ids, now)
+	}
+
+	m.mu.RLock()
+	keys := m.reapableInstancesLocked(now)
+	m.mu.RUnlock()
+	if len(keys) > 0 {
+		m.reapInstancesAt(keys, now)
+	}
+
+	m.mu.RLock()
+	sids := m.expiredSessionsLocked(now)
+	m.mu.RUnlock()
+	if len(sids) > 0 {
+		m.expireSessionsAt(sids, now)
+	}
+
+	m.mu.RLock()
+	w := m.tombstoneWatermarkLocked(now)
+	m.mu.RUnlock()
+	if w > 0 {
+		m.reapTombstones(w)
+	}
+}
+
+// expiredChecksLocked 返回在 now 时刻已超时但尚未标记为 critical 的 TTL 检查。
+// 调用方需持有读锁；结果按 ID 排序，保证生成的命令稳定。
+func (m *memoryRegistry) expiredChecksLocked(now time.Time) []string {
+	var ids []string
+	for _, id := range m.ttlDeadlines.due(now) {
+		if cr, ok := m.checks[id]; ok && cr.chk.Status != StatusCritical && ttlExpired(cr.chk, now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// expireChecksAt 将给定的 TTL 检查标记为 critical。
+// 每个检查都会以 now 重新校验是否仍超时，避免覆盖在提交期间到达的续约。
+func (m *memoryRegistry) expireChecksAt(ids []string, now time.Time) uint64 {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	// 记录哪些服务发生了变化
 	changedSvc := make(map[string]bool)
-	for _, cr := range m.checks {
-		if cr.chk.Spec.Type != CheckTTL {
+	for _, id := range ids {
+		cr, ok := m.checks[id]
+		if !ok || cr.chk.Status == StatusCritical || !ttlExpired(cr.chk, now) {
 			continue
 		}
-		ttl := cr.chk.Spec.TTL
-		if ttl <= 0 {
+		cr.chk.forceStatus(StatusCritical, now)
+		m.touchCheckLocked(id)
+		for _, svc := range m.servicesForCheckLocked(id) {
+			changedSvc[svc] = true
+		}
+	}
+	// 按服务键排序推进索引，保证各节点上的服务索引一致
+	svcs := make([]string, 0, len(changedSvc))
+	for svc := range changedSvc {
+		svcs = append(svcs, svc)
+	}
+	sort.Strings(svcs)
+	for _, svc := range svcs {
+		m.nextIndexLocked(svc)
+	}
+	m.invalidateSessionsLocked(now)
+	return m.index
+}
+
+func hasString(lst []string, s string) bool {
+	for _, v := range lst {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveWeight 返回实例在当前聚合状态下的权重；passing/warning 权重为 0 时取默认值，其他状态为 0。
+func effectiveWeight(v InstanceView) int {
+	switch v.status {
+	case StatusPassing:
+		if v.Weights.Passing > 0 {
+			return v.Weights.Passing
+		}
+		return DefaultPassingWeight
+	case StatusWarning:
+		if v.Weights.Warning > 0 {
+			return v.Weights.Warning
+		}
+		return DefaultWarningWeight
+	default:
+		return 0
+	}
+}
+
+// weightedShuffle 使用 Efraimidis-Spirakis 加权随机抽样对实例排序：
+// 每个实例取键 u^(1/w)，按键降序排列，排在前面的概率与权重成正比；权重为 0 的实例保持原顺序排在最后。
+func weightedShuffle(views []InstanceView) {
+	keys := make(map[string]float64, len(views))
+	for _, v := range views {
+		if w := effectiveWeight(v); w > 0 {
+			keys[v.ID] = math.Pow(rand.Float64(), 1/float64(w))
+		} else {
+			keys[v.ID] = -1
+		}
+	}
+	sort.SliceStable(views, func(i, j int) bool { return keys[views[i].ID] > keys[views[j].ID] })
+}
+
+// instanceZone 返回实例所在 zone：优先使用 Zone 字段，其次为 Meta["zone"]。
+func instanceZone(inst ServiceInstance) string {
+	if inst.Zone != "" {
+		return inst.Zone
+	}
+	return inst.Meta["zone"]
+}
+
+// orderByLocality 按就近原则排列实例；localOnly 为 true 时仅保留 near zone 的实例。
+func orderByLocality(views []InstanceView, near string, localOnly bool) []InstanceView {
+	if localOnly {
+		local := views[:0]
+		for _, v := range views {
+			if v.Zone == near {
+				local = append(local, v)
+			}
+		}
+		return local
+	}
+	sort.SliceStable(views, func(i, j int) bool {
+		return views[i].Zone == near && views[j].Zone != near
+	})
+	return views
+}
+
+// reapableInstancesLocked 返回在 now 时刻应被自动注销的实例键：
+// 实例的某个检查配置了 DeregisterCriticalAfter，且持续 critical 超过该时长。
+// 调用方需持有读锁；结果已排序。
+func (m *memoryRegistry) reapableInstancesLocked(now time.Time) []string {
+	var keys []string
+	for _, id := range m.reapDeadlines.due(now) {
+		if cr, ok := m.checks[id]; !ok || !criticalTooLong(cr.chk, now) {
 			continue
 		}
-		if cr.chk.LastPass.IsZero() {
+		if k, ok := m.checkOwner[id]; ok {
+			keys = appendUnique(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reapInstancesAt 注销持续 critical 超时的实例。
+// 每个实例都会以 now 重新校验，避免误删在提交期间已恢复的实例。
+func (m *memoryRegistry) reapInstancesAt(keys []string, now time.Time) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var changedSvc, changedNs []string
+	for _, k := range keys {
+		rec, ok := m.instances[k]
+		if !ok {
 			continue
 		}
-		if now.Sub(cr.chk.LastPass) > ttl {
-			if cr.chk.Status != StatusCritical {
-				cr.chk.Status = StatusCritical
-				cr.chk.LastUpdate = now
-				svc := m.findSvcKeyByCheckLocked(cr.chk.ID)
-				if svc != "" {
-					changedSvc[svc] = true
-				}
+		reap := false
+		for _, cid := range rec.checks {
+			if cr, ok := m.checks[cid]; ok && criticalTooLong(cr.chk, now) {
+				reap = true
+				break
 			}
 		}
+		if !reap {
+			continue
+		}
+		for _, cid := range rec.checks {
+			m.deleteCheckLocked(cid)
+		}
+		m.unindexInstanceLocked(k, rec)
+		delete(m.instances, k)
+		changedSvc = appendUnique(changedSvc, m.svcKey(rec.inst.Namespace, rec.inst.Service))
+		changedNs = appendUnique(changedNs, rec.inst.Namespace)
 	}
-	for svc := range changedSvc {
+	sort.Strings(changedSvc)
+	for _, svc := range changedSvc {
 		m.nextIndexLocked(svc)
 	}
+	sort.Strings(changedNs)
+	for _, ns := range changedNs {
+		m.touchNamespaceLocked(ns)
+	}
+	m.invalidateSessionsLocked(now)
+	return m.index
+}
+
+// criticalTooLong 判断检查是否已持续 critical 超过 DeregisterCriticalAfter。
+func criticalTooLong(chk Check, now time.Time) bool {
+	dca := chk.Spec.DeregisterCriticalAfter
+	if dca <= 0 || chk.Status != StatusCritical || chk.CriticalSince.IsZero() {
+		return false
+	}
+	return now.Sub(chk.CriticalSince) > dca
+}
+
+// latestTimeLocked 返回状态中记录的最晚时间（检查更新/续约、会话续约），无则为零值。
+func (m *memoryRegistry) latestTimeLocked() time.Time {
+	var t time.Time
+	for _, cr := range m.checks {
+		for _, v := range []time.Time{cr.chk.LastUpdate, cr.chk.LastPass} {
+			if v.After(t) {
+				t = v
+			}
+		}
+	}
+	for _, s := range m.sessions {
+		if s.LastRenew.After(t) {
+			t = s.LastRenew
+		}
+	}
+	return t
+}
+
+// ttlExpired 判断 TTL 检查在 now 时刻是否已超时（从未续约的检查不参与过期）。
+func ttlExpired(chk Check, now time.Time) bool {
+	if chk.Spec.Type != CheckTTL || chk.Spec.TTL <= 0 || chk.LastPass.IsZero() {
+		return false
+	}
+	return now.Sub(chk.LastPass) > chk.Spec.TTL
 }
 
 // StartExpirer 启动 TTL 过期清理器（若尚未启动）。
@@ -405,6 +1002,47 @@ func (m *memoryRegistry) Stop() {
 	}
 }
 
+// maintCheckID 生成实例维护模式合成检查的 ID。
+func maintCheckID(instID string) string {
+	return "_maint:" + instID
+}
+
+// checkID 生成实例第 i 个检查的 ID。
+func checkID(instID string, i int) string {
+	return "chk:" + instID + ":" + itoa(i)
+}
+
+// newCheck 按配置创建检查的初始状态。
+// 时长字段经归一化：若上游解析失败则为 0（等同未启用）。
+// TTL 检查在首次续约前标记为 critical。
+func newCheck(cid string, s CheckSpec, now time.Time) Check {
+	spec := normalizeSpec(s)
+	chk := Check{ID: cid, Spec: spec, Status: StatusUnknown, LastUpdate: now}
+	if spec.Type == CheckTTL {
+		// 尚未续约
+		chk.forceStatus(StatusCritical, now)
+	}
+	return chk
+}
+
+// normalizeSpec 从原始字符串补全时长字段。
+// 时长字段不参与 JSON 编码，经 Raft 日志或快照传递后只剩原始字符串。
+func normalizeSpec(s CheckSpec) CheckSpec {
+	if s.TTL == 0 && s.TTLRaw != "" {
+		s.TTL, _ = time.ParseDuration(s.TTLRaw)
+	}
+	if s.Interval == 0 && s.IntRaw != "" {
+		s.Interval, _ = time.ParseDuration(s.IntRaw)
+	}
+	if s.Timeout == 0 && s.TmRaw != "" {
+		s.Timeout, _ = time.ParseDuration(s.TmRaw)
+	}
+	if s.DeregisterCriticalAfter == 0 && s.DCARaw != "" {
+		s.DeregisterCriticalAfter, _ = time.ParseDuration(s.DCARaw)
+	}
+	return s
+}
+
 func cloneMap(in map[string]string) map[string]string {
 	if in == nil {
 		return nil
@@ -416,6 +1054,14 @@ func cloneMap(in map[string]string) map[string]string {
 	return out
 }
 
+// newUUID 生成随机的 UUID 格式 ID（会话、调用规则等）。
+// 经 Raft 提交的对象须在提交前生成 ID，保证各节点一致。
+func newUUID() string {
+	var b [16]byte
+	_, _ = crand.Read(b[:])
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
 // itoa：简单的整数转字符串，避免引入 strconv。
 func itoa(n int) string {
 	if n == 0 {
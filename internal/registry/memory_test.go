@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetMaintenanceDefaultsNamespace(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0)
+	for _, id := range []string{"w1", "w2"} {
+		if _, _, err := m.registerAt(ServiceInstance{Service: "web", ID: id}, nil, t0); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+	inMaint := func(id string) bool {
+		_, ok := m.checks[maintCheckID(id)]
+		return ok
+	}
+
+	// 仅指定服务、不指定命名空间时作用于 default 下的全部实例
+	if _, err := m.setMaintenanceAt("", "web", "", true, "upgrade", t0); err != nil {
+		t.Fatalf("enable by service: %v", err)
+	}
+	if !inMaint("w1") || !inMaint("w2") {
+		t.Fatalf("maintenance not applied to all instances: w1=%v w2=%v", inMaint("w1"), inMaint("w2"))
+	}
+	if _, err := m.setMaintenanceAt("", "web", "w1", false, "", t0); err != nil {
+		t.Fatalf("disable by service/id: %v", err)
+	}
+	if inMaint("w1") || !inMaint("w2") {
+		t.Fatalf("after disabling w1: w1=%v w2=%v", inMaint("w1"), inMaint("w2"))
+	}
+	if _, err := m.setMaintenanceAt("", "", "", true, "", t0); err == nil {
+		t.Fatalf("missing id and service should fail")
+	}
+}
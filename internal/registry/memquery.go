@@ -0,0 +1,139 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// memquery.go - memoryRegistry 的预设查询
+// 查询定义经 Raft 复制（opQuerySet/opQueryDel）；执行时在本地内存上依次查询主命名空间与回退命名空间。
+
+// UpsertPreparedQuery 创建或更新预设查询；过滤表达式在保存时校验，回退列表去重并剔除主命名空间。
+func (m *memoryRegistry) UpsertPreparedQuery(ctx context.Context, q PreparedQuery) (uint64, error) {
+	if q.Name == "" || strings.Contains(q.Name, "/") {
+		return 0, errors.New("missing or bad query Name")
+	}
+	if q.Service == "" {
+		return 0, errors.New("missing query Service")
+	}
+	if _, err := parseQueryFilter(q.Filter); err != nil {
+		return 0, err
+	}
+	q.Namespace = nsOrDefault(q.Namespace)
+	var failover []string
+	for _, ns := range q.Failover {
+		ns = nsOrDefault(ns)
+		if ns != q.Namespace && !hasString(failover, ns) {
+			failover = append(failover, ns)
+		}
+	}
+	q.Failover = failover
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.index++
+	if old, ok := m.queries[q.Name]; ok {
+		q.CreateIndex = old.CreateIndex
+	} else {
+		q.CreateIndex = m.index
+	}
+	q.ModifyIndex = m.index
+	m.queries[q.Name] = &q
+	return m.index, nil
+}
+
+func (m *memoryRegistry) DeletePreparedQuery(ctx context.Context, name string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.queries[name]; !ok {
+		return m.index, errors.New("query not found")
+	}
+	delete(m.queries, name)
+	m.index++
+	return m.index, nil
+}
+
+func (m *memoryRegistry) GetPreparedQuery(ctx context.Context, name string) (PreparedQuery, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	q, ok := m.queries[name]
+	if !ok {
+		return PreparedQuery{}, m.index, errors.New("query not found")
+	}
+	return clonePreparedQuery(*q), m.index, nil
+}
+
+func (m *memoryRegistry) ListPreparedQueries(ctx context.Context) ([]PreparedQuery, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]PreparedQuery, 0, len(m.queries))
+	for _, q := range m.queries {
+		out = append(out, clonePreparedQuery(*q))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, m.index, nil
+}
+
+// ExecutePreparedQuery 执行预设查询：依次查询主命名空间与回退命名空间，返回第一个存在 passing 实例的结果；
+// 全部没有 passing 实例时返回主命名空间的结果。idx 为应答命名空间中该服务的索引。
+func (m *memoryRegistry) ExecutePreparedQuery(ctx context.Context, name string) (PreparedQueryResult, uint64, error) {
+	q, _, err := m.GetPreparedQuery(ctx, name)
+	if err != nil {
+		return PreparedQueryResult{}, 0, err
+	}
+	f, err := parseQueryFilter(q.Filter)
+	if err != nil {
+		return PreparedQueryResult{}, 0, err
+	}
+	opts := ListOptions{PassingOnly: q.OnlyPassing, Tag: q.Tag, Filter: f}
+
+	var primary PreparedQueryResult
+	var primaryIdx uint64
+	for i, ns := range append([]string{q.Namespace}, q.Failover...) {
+		views, idx, err := m.ListHealthyInstances(ctx, ns, q.Service, opts)
+		if err != nil {
+			return PreparedQueryResult{}, 0, err
+		}
+		res := PreparedQueryResult{Query: q.Name, Service: q.Service, Namespace: ns, Failovers: i, Instances: views}
+		if res.Instances == nil {
+			res.Instances = []InstanceView{}
+		}
+		if i == 0 {
+			primary, primaryIdx = res, idx
+		}
+		if hasPassing(views) {
+			return res, idx, nil
+		}
+	}
+	return primary, primaryIdx, nil
+}
+
+// parseQueryFilter 解析查询的过滤表达式；为空时返回 nil（不过滤）。
+func parseQueryFilter(expr string) (*Filter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	f, err := ParseFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return f, nil
+}
+
+// hasPassing 判断结果中是否存在聚合状态为 passing 的实例。
+func hasPassing(views []InstanceView) bool {
+	for _, v := range views {
+		if v.status == StatusPassing {
+			return true
+		}
+	}
+	return false
+}
+
+func clonePreparedQuery(q PreparedQuery) PreparedQuery {
+	q.Failover = append([]string(nil), q.Failover...)
+	return q
+}
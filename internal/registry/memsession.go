@@ -0,0 +1,255 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// memsession.go - memoryRegistry 的会话与分布式锁
+// 会话经 Raft 复制（opSessionCreate/opSessionDestroy/opSessionRenew/opExpireSessions）；
+// 锁即带 Session 的键值条目（KVLock/KVUnlock，见 memkv.go）。
+// 会话失效时按 Behavior 释放或删除其持有的键，并对这些键施加 lock-delay，
+// 避免旧持有者尚未察觉失效时新持有者立即拿到锁。
+
+// CreateSession 创建会话；ID 为空时自动生成。
+func (m *memoryRegistry) CreateSession(ctx context.Context, s Session) (Session, uint64, error) {
+	if s.ID == "" {
+		s.ID = newUUID()
+	}
+	return m.createSessionAt(s, time.Now())
+}
+
+// createSessionAt 以给定时间创建会话；关联的检查必须存在且不处于 critical。
+func (m *memoryRegistry) createSessionAt(s Session, now time.Time) (Session, uint64, error) {
+	if s.ID == "" {
+		return Session{}, 0, errors.New("missing session ID")
+	}
+	s, err := normalizeSession(s)
+	if err != nil {
+		return Session{}, 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[s.ID]; ok {
+		return Session{}, m.index, errors.New("session already exists")
+	}
+	for _, cid := range s.Checks {
+		cr, ok := m.checks[cid]
+		if !ok {
+			return Session{}, m.index, fmt.Errorf("check not found: %s", cid)
+		}
+		if cr.chk.Status == StatusCritical {
+			return Session{}, m.index, fmt.Errorf("check is critical: %s", cid)
+		}
+	}
+	m.index++
+	s.CreateIndex, s.ModifyIndex = m.index, m.index
+	s.LastRenew = now
+	m.sessions[s.ID] = &s
+	m.indexSessionLocked(&s)
+	return cloneSession(s), m.index, nil
+}
+
+// DestroySession 销毁会话并按 Behavior 处理其持有的锁。
+func (m *memoryRegistry) DestroySession(ctx context.Context, id string) (uint64, error) {
+	return m.destroySessionAt(id, time.Now())
+}
+
+func (m *memoryRegistry) destroySessionAt(id string, now time.Time) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[id]; !ok {
+		return m.index, errors.New("session not found")
+	}
+	m.destroySessionLocked(id, now)
+	return m.index, nil
+}
+
+// RenewSession 续约会话的 TTL，返回续约后的会话。
+func (m *memoryRegistry) RenewSession(ctx context.Context, id string) (Session, uint64, error) {
+	return m.renewSessionAt(id, time.Now())
+}
+
+func (m *memoryRegistry) renewSessionAt(id string, now time.Time) (Session, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return Session{}, m.index, errors.New("session not found")
+	}
+	s.LastRenew = now
+	m.indexSessionLocked(s)
+	return cloneSession(*s), m.index, nil
+}
+
+func (m *memoryRegistry) GetSession(ctx context.Context, id string) (Session, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return Session{}, m.index, errors.New("session not found")
+	}
+	return cloneSession(*s), m.index, nil
+}
+
+func (m *memoryRegistry) ListSessions(ctx context.Context) ([]Session, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		out = append(out, cloneSession(*s))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, m.index, nil
+}
+
+// expiredSessionsLocked 返回在 now 时刻已超过 TTL 未续约的会话。
+// 调用方需持有读锁；结果已排序。
+func (m *memoryRegistry) expiredSessionsLocked(now time.Time) []string {
+	var ids []string
+	for _, id := range m.sessionDeadlines.due(now) {
+		if s, ok := m.sessions[id]; ok && sessionExpired(*s, now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// expireSessionsAt 销毁给定的过期会话；每个会话都会以 now 重新校验，避免误删提交期间已续约的会话。
+func (m *memoryRegistry) expireSessionsAt(ids []string, now time.Time) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		if s, ok := m.sessions[id]; ok && sessionExpired(*s, now) {
+			m.destroySessionLocked(id, now)
+		}
+	}
+	return m.index
+}
+
+// --- 内部方法 ---
+
+// destroySessionLocked 删除会话，并按键排序逐个释放（或删除）其持有的锁。
+func (m *memoryRegistry) destroySessionLocked(id string, now time.Time) {
+	s := m.sessions[id]
+	delete(m.sessions, id)
+	m.unindexSessionLocked(s)
+	m.index++
+
+	var keys []string
+	for k, e := range m.kv {
+		if e.Session == id {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if s.LockDelay > 0 {
+			m.lockDelays[k] = now.Add(s.LockDelay)
+		}
+		if s.Behavior == SessionDelete {
+			m.kvDeleteLocked(k)
+			continue
+		}
+		m.index++
+		e := m.kv[k]
+		e.Session = ""
+		e.ModifyIndex = m.index
+		m.notifyKVLocked(k)
+	}
+}
+
+// invalidateSessionsLocked 销毁关联检查已变为 critical 或已被删除的会话。
+// 在所有可能改变检查状态或删除检查的写路径末尾调用；只检查 staleChecks 中记下的检查，
+// 按 ID 排序保证各节点索引一致。
+func (m *memoryRegistry) invalidateSessionsLocked(now time.Time) {
+	if len(m.staleChecks) == 0 {
+		return
+	}
+	set := make(map[string]struct{})
+	for cid := range m.staleChecks {
+		if cr, ok := m.checks[cid]; ok && cr.chk.Status != StatusCritical {
+			continue
+		}
+		for id := range m.checkSessions[cid] {
+			set[id] = struct{}{}
+		}
+	}
+	clear(m.staleChecks)
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		m.destroySessionLocked(id, now)
+	}
+}
+
+// lockDelayedLocked 判断键在 now 时刻是否仍处于 lock-delay；已过期的记录顺带清理。
+func (m *memoryRegistry) lockDelayedLocked(key string, now time.Time) bool {
+	until, ok := m.lockDelays[key]
+	if !ok {
+		return false
+	}
+	if now.Before(until) {
+		return true
+	}
+	delete(m.lockDelays, key)
+	return false
+}
+
+// sessionExpired 判断会话在 now 时刻是否已超过 TTL 未续约（未设置 TTL 的会话不过期）。
+func sessionExpired(s Session, now time.Time) bool {
+	return s.TTL > 0 && now.Sub(s.LastRenew) > s.TTL
+}
+
+// normalizeSession 解析时长字段并补全默认值，校验取值范围。
+// 与 CheckSpec 相同，时长字段不参与 JSON 编码，经 Raft 日志或快照传递后从原始字符串恢复。
+func normalizeSession(s Session) (Session, error) {
+	if s.TTL == 0 && s.TTLRaw != "" {
+		d, err := time.ParseDuration(s.TTLRaw)
+		if err != nil {
+			return s, fmt.Errorf("bad TTL: %w", err)
+		}
+		s.TTL = d
+	}
+	if s.TTL != 0 && (s.TTL < SessionTTLMin || s.TTL > SessionTTLMax) {
+		return s, fmt.Errorf("TTL must be between %s and %s", SessionTTLMin, SessionTTLMax)
+	}
+	if s.LockDelay == 0 {
+		if s.LockDelayRaw == "" {
+			s.LockDelay = DefaultLockDelay
+		} else {
+			d, err := time.ParseDuration(s.LockDelayRaw)
+			if err != nil {
+				return s, fmt.Errorf("bad LockDelay: %w", err)
+			}
+			s.LockDelay = d
+		}
+	}
+	if s.LockDelay < 0 || s.LockDelay > SessionLockDelayMax {
+		return s, fmt.Errorf("LockDelay must be between 0s and %s", SessionLockDelayMax)
+	}
+	switch s.Behavior {
+	case "":
+		s.Behavior = SessionRelease
+	case SessionRelease, SessionDelete:
+	default:
+		return s, fmt.Errorf("unknown session Behavior: %s", s.Behavior)
+	}
+	if s.TTL > 0 {
+		s.TTLRaw = s.TTL.String()
+	}
+	s.LockDelayRaw = s.LockDelay.String()
+	return s, nil
+}
+
+func cloneSession(s Session) Session {
+	s.Checks = append([]string{}, s.Checks...)
+	return s
+}
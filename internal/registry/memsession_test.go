@@ -0,0 +1,155 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessionInvalidation(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	type env struct {
+		m   *memoryRegistry
+		cid string // 会话关联的检查
+		now time.Time
+	}
+	triggers := map[string]func(e env){
+		"destroy": func(e env) { e.m.destroySessionAt("s1", e.now) },
+		"ttl expiry": func(e env) {
+			e.m.expireSessionsAt([]string{"s1"}, e.now.Add(time.Minute))
+		},
+		"check critical": func(e env) {
+			e.m.reportCheckAt(e.cid, StatusCritical, "down", "", e.now)
+		},
+		"check ttl expired": func(e env) {
+			e.m.expireChecksAt([]string{e.cid}, e.now.Add(time.Minute))
+		},
+		"instance deregistered": func(e env) {
+			e.m.deregisterAt(DefaultNamespace, "web", "w1", e.now)
+		},
+		"check warning": func(e env) {
+			e.m.reportCheckAt(e.cid, StatusWarning, "slow", "", e.now)
+		},
+		"maintenance": func(e env) {
+			e.m.setMaintenanceAt(DefaultNamespace, "web", "w1", true, "", e.now)
+		},
+		"renew then expiry scan": func(e env) {
+			e.m.renewSessionAt("s1", e.now.Add(20*time.Second))
+			e.m.mu.RLock()
+			ids := e.m.expiredSessionsLocked(e.now.Add(40 * time.Second))
+			e.m.mu.RUnlock()
+			e.m.expireSessionsAt(ids, e.now.Add(40*time.Second))
+		},
+	}
+	tests := []struct {
+		trigger     string
+		behavior    SessionBehavior
+		invalidated bool
+	}{
+		{"destroy", SessionRelease, true},
+		{"destroy", SessionDelete, true},
+		{"ttl expiry", SessionRelease, true},
+		{"ttl expiry", SessionDelete, true},
+		{"check critical", SessionRelease, true},
+		{"check critical", SessionDelete, true},
+		{"check ttl expired", SessionRelease, true},
+		{"instance deregistered", SessionRelease, true},
+		{"instance deregistered", SessionDelete, true},
+		{"check warning", SessionRelease, false},
+		{"maintenance", SessionRelease, false},
+		{"renew then expiry scan", SessionRelease, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.trigger+"/"+string(tt.behavior), func(t *testing.T) {
+			m := NewMemoryRegistryWithOptions(Options{})
+			spec := CheckSpec{Type: CheckTTL, TTL: 10 * time.Second}
+			_, ids, err := m.registerAt(ServiceInstance{Service: "web", ID: "w1"}, []CheckSpec{spec}, t0)
+			if err != nil {
+				t.Fatalf("register: %v", err)
+			}
+			cid := ids[0]
+			if _, err := m.reportCheckAt(cid, StatusPassing, "", "", t0); err != nil {
+				t.Fatalf("pass: %v", err)
+			}
+			sess := Session{ID: "s1", Checks: []string{cid}, Behavior: tt.behavior, TTLRaw: "30s", LockDelayRaw: "5s"}
+			if _, _, err := m.createSessionAt(sess, t0); err != nil {
+				t.Fatalf("create session: %v", err)
+			}
+			if _, _, err := m.createSessionAt(Session{ID: "s2"}, t0); err != nil {
+				t.Fatalf("create session: %v", err)
+			}
+			if ok, _, err := m.kvApplyAt(KVOp{Verb: KVLock, Key: "lock", Value: []byte("v"), Session: "s1"}, t0); !ok || err != nil {
+				t.Fatalf("lock: ok=%v err=%v", ok, err)
+			}
+			lockIdx := m.index
+
+			now := t0.Add(time.Second)
+			triggers[tt.trigger](env{m: m, cid: cid, now: now})
+
+			ctx := context.Background()
+			_, _, err = m.GetSession(ctx, "s1")
+			if gone := err != nil; gone != tt.invalidated {
+				t.Fatalf("session gone = %v, want %v", gone, tt.invalidated)
+			}
+			e, idx, _ := m.KVGet(ctx, "lock")
+			if !tt.invalidated {
+				if e == nil || e.Session != "s1" {
+					t.Fatalf("lock = %+v, want still held by s1", e)
+				}
+				return
+			}
+			if idx <= lockIdx {
+				t.Fatalf("lock key index = %d, want > %d", idx, lockIdx)
+			}
+			switch tt.behavior {
+			case SessionDelete:
+				if e != nil {
+					t.Fatalf("lock key = %+v, want deleted", e)
+				}
+			default:
+				if e == nil || e.Session != "" || string(e.Value) != "v" {
+					t.Fatalf("lock key = %+v, want released with value kept", e)
+				}
+			}
+
+			// lock-delay 自失效时刻起算：期间其他会话无法加锁，过后可以
+			if ok, _, _ := m.kvApplyAt(KVOp{Verb: KVLock, Key: "lock", Session: "s2"}, now); ok {
+				t.Fatalf("lock acquired during lock-delay")
+			}
+			if ok, _, err := m.kvApplyAt(KVOp{Verb: KVLock, Key: "lock", Session: "s2"}, now.Add(2*time.Minute)); !ok || err != nil {
+				t.Fatalf("lock after lock-delay: ok=%v err=%v", ok, err)
+			}
+		})
+	}
+}
+
+func TestCreateSessionRejectsUnhealthyChecks(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0)
+	_, ids, err := m.registerAt(ServiceInstance{Service: "web", ID: "w1"}, []CheckSpec{{Type: CheckTTL, TTL: 10 * time.Second}}, t0)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	m.reportCheckAt(ids[0], StatusCritical, "", "", t0)
+	tests := []struct {
+		name string
+		sess Session
+	}{
+		{"critical check", Session{ID: "a", Checks: []string{ids[0]}}},
+		{"unknown check", Session{ID: "b", Checks: []string{"nope"}}},
+		{"ttl too short", Session{ID: "c", TTLRaw: "1s"}},
+		{"bad lock delay", Session{ID: "d", LockDelayRaw: "-1s"}},
+		{"unknown behavior", Session{ID: "e", Behavior: "keep"}},
+		{"missing id", Session{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := m.createSessionAt(tt.sess, t0); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+	if len(m.sessions) != 0 {
+		t.Fatalf("sessions = %d, want 0", len(m.sessions))
+	}
+}
@@ -0,0 +1,171 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// memsplitter.go - memoryRegistry 的子集定义与流量拆分（service-splitter）
+// 配置经 Raft 复制（opSplitterSet/opSplitterDel）；变更推进该服务的索引，使解析结果的长轮询能及时返回。
+// 解析时在本地内存上复用 ListHealthyInstances，按子集的标签与过滤表达式分组。
+
+// UpsertServiceSplitter 创建或更新服务的流量拆分配置；子集与拆分在保存时校验。
+func (m *memoryRegistry) UpsertServiceSplitter(ctx context.Context, s ServiceSplitter) (uint64, error) {
+	s.Namespace = nsOrDefault(s.Namespace)
+	if s.Name == "" {
+		return 0, errors.New("missing service Name")
+	}
+	if err := validateServiceSplitter(s); err != nil {
+		return 0, err
+	}
+	svc := m.svcKey(s.Namespace, s.Name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := m.nextIndexLocked(svc)
+	if old, ok := m.serviceSplitters[svc]; ok {
+		s.CreateIndex = old.CreateIndex
+	} else {
+		s.CreateIndex = idx
+	}
+	s.ModifyIndex = idx
+	m.serviceSplitters[svc] = &s
+	return idx, nil
+}
+
+func (m *memoryRegistry) DeleteServiceSplitter(ctx context.Context, namespace, name string) (uint64, error) {
+	svc := m.svcKey(nsOrDefault(namespace), name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.serviceSplitters[svc]; !ok {
+		return m.index, errors.New("service splitter not found")
+	}
+	delete(m.serviceSplitters, svc)
+	return m.nextIndexLocked(svc), nil
+}
+
+func (m *memoryRegistry) GetServiceSplitter(ctx context.Context, namespace, name string) (ServiceSplitter, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.serviceSplitters[m.svcKey(nsOrDefault(namespace), name)]
+	if !ok {
+		return ServiceSplitter{}, m.index, errors.New("service splitter not found")
+	}
+	return cloneServiceSplitter(*s), m.index, nil
+}
+
+// ListServiceSplitters 列出流量拆分配置；namespace 为空时列出全部命名空间。
+func (m *memoryRegistry) ListServiceSplitters(ctx context.Context, namespace string) ([]ServiceSplitter, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []ServiceSplitter{}
+	for _, s := range m.serviceSplitters {
+		if namespace == "" || s.Namespace == namespace {
+			out = append(out, cloneServiceSplitter(*s))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, m.index, nil
+}
+
+// ResolveService 按流量拆分配置将服务实例分组；opts 应用于全部子集（子集自身的标签与过滤条件叠加其上）。
+// 一个实例可同时属于多个子集。idx 为该服务的索引。
+func (m *memoryRegistry) ResolveService(ctx context.Context, namespace, service string, opts ListOptions) (ServiceResolution, uint64, error) {
+	namespace = nsOrDefault(namespace)
+	views, idx, err := m.ListHealthyInstances(ctx, namespace, service, opts)
+	if err != nil {
+		return ServiceResolution{}, 0, err
+	}
+	res := ServiceResolution{Namespace: namespace, Service: service}
+	s, _, err := m.GetServiceSplitter(ctx, namespace, service)
+	if err != nil {
+		if views == nil {
+			views = []InstanceView{}
+		}
+		res.Subsets = []ResolvedSubset{{Percent: 100, Instances: views}}
+		return res, idx, nil
+	}
+
+	percent := make(map[string]int, len(s.Splits))
+	for _, sp := range s.Splits {
+		percent[sp.Subset] = sp.Percent
+	}
+	res.Subsets = make([]ResolvedSubset, 0, len(s.Subsets))
+	for _, sub := range s.Subsets {
+		f, err := parseQueryFilter(sub.Filter)
+		if err != nil {
+			return ServiceResolution{}, 0, err
+		}
+		rs := ResolvedSubset{Name: sub.Name, Percent: percent[sub.Name], Instances: []InstanceView{}}
+		for _, v := range views {
+			if sub.Tag != "" && !hasString(v.Tags, sub.Tag) {
+				continue
+			}
+			if f != nil && !f.Match(v) {
+				continue
+			}
+			rs.Instances = append(rs.Instances, v)
+		}
+		res.Subsets = append(res.Subsets, rs)
+	}
+	return res, idx, nil
+}
+
+// --- 内部方法 ---
+
+// validateServiceSplitter 校验子集名唯一且过滤表达式合法，拆分非空、引用已定义的子集且百分比之和为 100。
+func validateServiceSplitter(s ServiceSplitter) error {
+	if len(s.Subsets) == 0 {
+		return errors.New("at least one subset is required")
+	}
+	subsets := make(map[string]bool, len(s.Subsets))
+	for _, sub := range s.Subsets {
+		if sub.Name == "" || strings.Contains(sub.Name, "/") {
+			return errors.New("missing or bad subset Name")
+		}
+		if subsets[sub.Name] {
+			return fmt.Errorf("duplicate subset: %s", sub.Name)
+		}
+		subsets[sub.Name] = true
+		if _, err := parseQueryFilter(sub.Filter); err != nil {
+			return fmt.Errorf("subset %s: %w", sub.Name, err)
+		}
+	}
+	if len(s.Splits) == 0 {
+		// 没有拆分时所有子集的百分比均为 0，客户端无从分配流量
+		return errors.New("at least one split is required")
+	}
+	seen := make(map[string]bool, len(s.Splits))
+	total := 0
+	for _, sp := range s.Splits {
+		if !subsets[sp.Subset] {
+			return fmt.Errorf("split references unknown subset: %s", sp.Subset)
+		}
+		if seen[sp.Subset] {
+			return fmt.Errorf("duplicate split for subset: %s", sp.Subset)
+		}
+		seen[sp.Subset] = true
+		if sp.Percent < 0 || sp.Percent > 100 {
+			return fmt.Errorf("split Percent must be between 0 and 100: %s", sp.Subset)
+		}
+		total += sp.Percent
+	}
+	if total != 100 {
+		return fmt.Errorf("split percentages must sum to 100, got %d", total)
+	}
+	return nil
+}
+
+func cloneServiceSplitter(s ServiceSplitter) ServiceSplitter {
+	s.Subsets = append([]ServiceSubset(nil), s.Subsets...)
+	s.Splits = append([]ServiceSplit(nil), s.Splits...)
+	return s
+}
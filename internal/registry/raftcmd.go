@@ -1,6 +1,9 @@
 package registry
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // raftcmd.go - Raft 命令和响应类型定义
 // 将 Raft 日志命令的编解码逻辑集中管理，便于维护和测试。
@@ -10,10 +13,34 @@ import "encoding/json"
 // ============================================================================
 
 const (
-	opRegister    = "register"
-	opDeregister  = "deregister"
-	opRenewTTL    = "renew_ttl"
-	opReportCheck = "report_check"
+	opRegister       = "register"
+	opDeregister     = "deregister"
+	opRenewTTL       = "renew_ttl"
+	opReportCheck    = "report_check"
+	opExpireChecks   = "expire_checks"
+	opSetServer      = "set_server"
+	opReapCritical   = "reap_critical"
+	opMaintenance    = "maintenance"
+	opRegisterNode   = "register_node"
+	opDeregisterNode = "deregister_node"
+	opNamespaceSet   = "namespace_set"
+	opNamespaceDel   = "namespace_delete"
+	opNamespaceCfg   = "namespace_config"
+	opKV             = "kv"
+	opSessionCreate  = "session_create"
+	opSessionDestroy = "session_destroy"
+	opSessionRenew   = "session_renew"
+	opExpireSessions = "expire_sessions"
+	opReapTombstones = "reap_tombstones"
+	opQuerySet       = "query_set"
+	opQueryDel       = "query_delete"
+	opConfigSet      = "config_set"
+	opConfigDel      = "config_delete"
+	opIntentionSet   = "intention_set"
+	opIntentionDel   = "intention_delete"
+	opIntentionCfg   = "intention_config"
+	opSplitterSet    = "splitter_set"
+	opSplitterDel    = "splitter_delete"
 )
 
 // ============================================================================
@@ -21,9 +48,11 @@ const (
 // ============================================================================
 
 // commandEnvelope 是所有 Raft 命令的外层包装，包含操作类型和数据负载。
+// At 为构建命令时的时间（UnixNano），日志缺少 AppendedAt 时作为应用时间的后备；旧版本写入的命令没有该字段。
 type commandEnvelope struct {
 	Op   string          `json:"op"`
 	Data json.RawMessage `json:"data"`
+	At   int64           `json:"at,omitempty"`
 }
 
 // ============================================================================
@@ -48,6 +77,98 @@ type checkCommand struct {
 	ID     string `json:"id"`
 	Status string `json:"status,omitempty"` // 仅用于 report_check
 	Output string `json:"output,omitempty"` // 仅用于 report_check
+	Note   string `json:"note,omitempty"`   // 仅用于 report_check
+}
+
+// expireChecksCommand TTL 过期命令（由 Leader 扫描后提交）
+type expireChecksCommand struct {
+	CheckIDs []string `json:"check_ids"`
+}
+
+// maintenanceCommand 开启/关闭维护模式命令
+type maintenanceCommand struct {
+	Namespace string `json:"ns"`
+	Service   string `json:"svc"`
+	ID        string `json:"id"`
+	Enable    bool   `json:"enable"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// reapCriticalCommand 自动注销持续 critical 实例的命令（由 Leader 扫描后提交）
+type reapCriticalCommand struct {
+	Keys []string `json:"keys"`
+}
+
+// registerNodeCommand 注册节点命令
+type registerNodeCommand struct {
+	Node  Node        `json:"node"`
+	Specs []CheckSpec `json:"specs"`
+}
+
+// deregisterNodeCommand 注销节点命令
+type deregisterNodeCommand struct {
+	Name string `json:"name"`
+}
+
+// namespaceCommand 创建/更新命名空间命令（删除时仅使用 Name）
+type namespaceCommand struct {
+	Namespace Namespace `json:"namespace"`
+}
+
+// namespaceConfigCommand 更新命名空间全局配置命令
+type namespaceConfigCommand struct {
+	Config NamespaceConfig `json:"config"`
+}
+
+// kvCommand 键值写操作命令
+type kvCommand struct {
+	Op KVOp `json:"op"`
+}
+
+// sessionCommand 创建/销毁/续约会话命令（销毁与续约时仅使用 ID）
+type sessionCommand struct {
+	Session Session `json:"session"`
+}
+
+// expireSessionsCommand 会话过期命令（由 Leader 扫描后提交）
+type expireSessionsCommand struct {
+	IDs []string `json:"ids"`
+}
+
+// reapTombstonesCommand 键值墓碑回收命令（由 Leader 按索引水位提交）
+type reapTombstonesCommand struct {
+	Index uint64 `json:"index"`
+}
+
+// queryCommand 创建/更新预设查询命令（删除时仅使用 Name）
+type queryCommand struct {
+	Query PreparedQuery `json:"query"`
+}
+
+// configCommand 创建/更新服务级配置项命令（删除时仅使用 Namespace/Name）
+type configCommand struct {
+	Defaults ServiceDefaults `json:"defaults"`
+}
+
+// intentionCommand 创建/更新调用规则命令（删除时仅使用 ID）
+type intentionCommand struct {
+	Intention Intention `json:"intention"`
+}
+
+// intentionConfigCommand 更新调用规则全局配置命令
+type intentionConfigCommand struct {
+	Config IntentionConfig `json:"config"`
+}
+
+// splitterCommand 创建/更新流量拆分配置命令（删除时仅使用 Namespace/Name）
+type splitterCommand struct {
+	Splitter ServiceSplitter `json:"splitter"`
+}
+
+// serverCommand 发布 Raft 节点的 HTTP 地址命令
+type serverCommand struct {
+	ID       string `json:"id"`
+	HTTPAddr string `json:"http_addr"`
 }
 
 // ============================================================================
@@ -61,6 +182,20 @@ type registerResponse struct {
 	Err      string   `json:"err,omitempty"`
 }
 
+// kvResponse 键值写操作响应；OK 为 false 表示 CAS 条件不满足
+type kvResponse struct {
+	Index uint64 `json:"index"`
+	OK    bool   `json:"ok"`
+	Err   string `json:"err,omitempty"`
+}
+
+// sessionResponse 创建/续约会话响应
+type sessionResponse struct {
+	Index   uint64   `json:"index"`
+	Session *Session `json:"session,omitempty"`
+	Err     string   `json:"err,omitempty"`
+}
+
 // indexResponse 通用索引响应（用于注销、续约、报告等）
 type indexResponse struct {
 	Index uint64 `json:"index"`
@@ -77,7 +212,7 @@ func buildCommand(op string, data interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	env := commandEnvelope{Op: op, Data: payload}
+	env := commandEnvelope{Op: op, Data: payload, At: time.Now().UnixNano()}
 	return json.Marshal(env)
 }
 
@@ -101,14 +236,141 @@ func BuildRenewTTLCommand(checkID string) ([]byte, error) {
 }
 
 // BuildReportCheckCommand 构建健康检查报告命令
-func BuildReportCheckCommand(checkID string, status CheckStatus, output string) ([]byte, error) {
+func BuildReportCheckCommand(checkID string, status CheckStatus, output, note string) ([]byte, error) {
 	return buildCommand(opReportCheck, checkCommand{
 		ID:     checkID,
 		Status: statusString(status),
 		Output: output,
+		Note:   note,
 	})
 }
 
+// BuildExpireChecksCommand 构建 TTL 过期命令
+func BuildExpireChecksCommand(checkIDs []string) ([]byte, error) {
+	return buildCommand(opExpireChecks, expireChecksCommand{CheckIDs: checkIDs})
+}
+
+// BuildMaintenanceCommand 构建维护模式命令
+func BuildMaintenanceCommand(namespace, service, id string, enable bool, reason string) ([]byte, error) {
+	return buildCommand(opMaintenance, maintenanceCommand{
+		Namespace: namespace,
+		Service:   service,
+		ID:        id,
+		Enable:    enable,
+		Reason:    reason,
+	})
+}
+
+// BuildReapCriticalCommand 构建自动注销命令
+func BuildReapCriticalCommand(keys []string) ([]byte, error) {
+	return buildCommand(opReapCritical, reapCriticalCommand{Keys: keys})
+}
+
+// BuildRegisterNodeCommand 构建注册节点命令
+func BuildRegisterNodeCommand(node Node, specs []CheckSpec) ([]byte, error) {
+	return buildCommand(opRegisterNode, registerNodeCommand{Node: node, Specs: specs})
+}
+
+// BuildDeregisterNodeCommand 构建注销节点命令
+func BuildDeregisterNodeCommand(name string) ([]byte, error) {
+	return buildCommand(opDeregisterNode, deregisterNodeCommand{Name: name})
+}
+
+// BuildNamespaceSetCommand 构建创建/更新命名空间命令
+func BuildNamespaceSetCommand(ns Namespace) ([]byte, error) {
+	return buildCommand(opNamespaceSet, namespaceCommand{Namespace: ns})
+}
+
+// BuildNamespaceDeleteCommand 构建删除命名空间命令
+func BuildNamespaceDeleteCommand(name string) ([]byte, error) {
+	return buildCommand(opNamespaceDel, namespaceCommand{Namespace: Namespace{Name: name}})
+}
+
+// BuildNamespaceConfigCommand 构建更新命名空间全局配置命令
+func BuildNamespaceConfigCommand(c NamespaceConfig) ([]byte, error) {
+	return buildCommand(opNamespaceCfg, namespaceConfigCommand{Config: c})
+}
+
+// BuildKVCommand 构建键值写操作命令
+func BuildKVCommand(op KVOp) ([]byte, error) {
+	return buildCommand(opKV, kvCommand{Op: op})
+}
+
+// BuildSessionCreateCommand 构建创建会话命令（ID 须已由调用方生成，保证各节点一致）
+func BuildSessionCreateCommand(s Session) ([]byte, error) {
+	return buildCommand(opSessionCreate, sessionCommand{Session: s})
+}
+
+// BuildSessionDestroyCommand 构建销毁会话命令
+func BuildSessionDestroyCommand(id string) ([]byte, error) {
+	return buildCommand(opSessionDestroy, sessionCommand{Session: Session{ID: id}})
+}
+
+// BuildSessionRenewCommand 构建续约会话命令
+func BuildSessionRenewCommand(id string) ([]byte, error) {
+	return buildCommand(opSessionRenew, sessionCommand{Session: Session{ID: id}})
+}
+
+// BuildExpireSessionsCommand 构建会话过期命令
+func BuildExpireSessionsCommand(ids []string) ([]byte, error) {
+	return buildCommand(opExpireSessions, expireSessionsCommand{IDs: ids})
+}
+
+// BuildReapTombstonesCommand 构建键值墓碑回收命令
+func BuildReapTombstonesCommand(index uint64) ([]byte, error) {
+	return buildCommand(opReapTombstones, reapTombstonesCommand{Index: index})
+}
+
+// BuildQuerySetCommand 构建创建/更新预设查询命令
+func BuildQuerySetCommand(q PreparedQuery) ([]byte, error) {
+	return buildCommand(opQuerySet, queryCommand{Query: q})
+}
+
+// BuildQueryDeleteCommand 构建删除预设查询命令
+func BuildQueryDeleteCommand(name string) ([]byte, error) {
+	return buildCommand(opQueryDel, queryCommand{Query: PreparedQuery{Name: name}})
+}
+
+// BuildConfigSetCommand 构建创建/更新服务级配置项命令
+func BuildConfigSetCommand(d ServiceDefaults) ([]byte, error) {
+	return buildCommand(opConfigSet, configCommand{Defaults: d})
+}
+
+// BuildConfigDeleteCommand 构建删除服务级配置项命令
+func BuildConfigDeleteCommand(namespace, name string) ([]byte, error) {
+	return buildCommand(opConfigDel, configCommand{Defaults: ServiceDefaults{Namespace: namespace, Name: name}})
+}
+
+// BuildIntentionSetCommand 构建创建/更新调用规则命令（ID 须已由调用方生成，保证各节点一致）
+func BuildIntentionSetCommand(ix Intention) ([]byte, error) {
+	return buildCommand(opIntentionSet, intentionCommand{Intention: ix})
+}
+
+// BuildIntentionDeleteCommand 构建删除调用规则命令
+func BuildIntentionDeleteCommand(id string) ([]byte, error) {
+	return buildCommand(opIntentionDel, intentionCommand{Intention: Intention{ID: id}})
+}
+
+// BuildIntentionConfigCommand 构建更新调用规则全局配置命令
+func BuildIntentionConfigCommand(c IntentionConfig) ([]byte, error) {
+	return buildCommand(opIntentionCfg, intentionConfigCommand{Config: c})
+}
+
+// BuildSplitterSetCommand 构建创建/更新流量拆分配置命令
+func BuildSplitterSetCommand(s ServiceSplitter) ([]byte, error) {
+	return buildCommand(opSplitterSet, splitterCommand{Splitter: s})
+}
+
+// BuildSplitterDeleteCommand 构建删除流量拆分配置命令
+func BuildSplitterDeleteCommand(namespace, name string) ([]byte, error) {
+	return buildCommand(opSplitterDel, splitterCommand{Splitter: ServiceSplitter{Namespace: namespace, Name: name}})
+}
+
+// BuildSetServerCommand 构建发布节点 HTTP 地址命令
+func BuildSetServerCommand(id, httpAddr string) ([]byte, error) {
+	return buildCommand(opSetServer, serverCommand{ID: id, HTTPAddr: httpAddr})
+}
+
 // ============================================================================
 // 响应解析辅助函数
 // ============================================================================
@@ -137,6 +399,33 @@ func ParseIndexResponse(data []byte) (index uint64, err error) {
 	return resp.Index, nil
 }
 
+// ParseKVResponse 解析键值写操作响应
+func ParseKVResponse(data []byte) (ok bool, index uint64, err error) {
+	var resp kvResponse
+	if e := json.Unmarshal(data, &resp); e != nil {
+		return false, 0, e
+	}
+	if resp.Err != "" {
+		return false, resp.Index, errString(resp.Err)
+	}
+	return resp.OK, resp.Index, nil
+}
+
+// ParseSessionResponse 解析创建/续约会话响应
+func ParseSessionResponse(data []byte) (s Session, index uint64, err error) {
+	var resp sessionResponse
+	if e := json.Unmarshal(data, &resp); e != nil {
+		return Session{}, 0, e
+	}
+	if resp.Err != "" {
+		return Session{}, resp.Index, errString(resp.Err)
+	}
+	if resp.Session != nil {
+		s = *resp.Session
+	}
+	return s, resp.Index, nil
+}
+
 // ============================================================================
 // 内部辅助函数
 // ============================================================================
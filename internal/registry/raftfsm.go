@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	hraft "github.com/hashicorp/raft"
 )
@@ -22,6 +27,9 @@ import (
 type raftFSM struct {
 	mem *memoryRegistry
 	mu  sync.Mutex // 保护 Snapshot 期间的并发读
+
+	// clock 为已应用日志的时间水位，作为缺少时间的旧日志的应用时间（见 applyTime）；随快照保存
+	clock time.Time
 }
 
 // NewRaftFSMForServer 供 server 组装 Raft 使用
@@ -41,21 +49,75 @@ func (f *raftFSM) Apply(l *hraft.Log) interface{} {
 		return encodeResponse(indexResponse{Err: err.Error()})
 	}
 
+	// 时间源取自日志本身，而非本地 time.Now()，以保证各节点及重启回放得到相同的状态。
+	now := f.applyTime(l, env)
+
 	// 根据操作类型分发处理
 	switch env.Op {
 	case opRegister:
-		return f.applyRegister(env.Data)
+		return f.applyRegister(env.Data, now)
 	case opDeregister:
-		return f.applyDeregister(env.Data)
+		return f.applyDeregister(env.Data, now)
 	case opRenewTTL:
-		return f.applyRenewTTL(env.Data)
+		return f.applyRenewTTL(env.Data, now)
 	case opReportCheck:
-		return f.applyReportCheck(env.Data)
+		return f.applyReportCheck(env.Data, now)
+	case opExpireChecks:
+		return f.applyExpireChecks(env.Data, now)
+	case opMaintenance:
+		return f.applyMaintenance(env.Data, now)
+	case opReapCritical:
+		return f.applyReapCritical(env.Data, now)
+	case opSetServer:
+		return f.applySetServer(env.Data)
+	case opRegisterNode:
+		return f.applyRegisterNode(env.Data, now)
+	case opDeregisterNode:
+		return f.applyDeregisterNode(env.Data, now)
+	case opNamespaceSet, opNamespaceDel:
+		return f.applyNamespace(env.Op, env.Data)
+	case opNamespaceCfg:
+		return f.applyNamespaceConfig(env.Data)
+	case opKV:
+		return f.applyKV(env.Data, now)
+	case opSessionCreate, opSessionDestroy, opSessionRenew:
+		return f.applySession(env.Op, env.Data, now)
+	case opExpireSessions:
+		return f.applyExpireSessions(env.Data, now)
+	case opReapTombstones:
+		return f.applyReapTombstones(env.Data)
+	case opQuerySet, opQueryDel:
+		return f.applyQuery(env.Op, env.Data)
+	case opConfigSet, opConfigDel:
+		return f.applyConfig(env.Op, env.Data)
+	case opIntentionSet, opIntentionDel:
+		return f.applyIntention(env.Op, env.Data)
+	case opIntentionCfg:
+		return f.applyIntentionConfig(env.Data)
+	case opSplitterSet, opSplitterDel:
+		return f.applySplitter(env.Op, env.Data)
 	default:
 		return encodeResponse(indexResponse{Err: "unknown op: " + env.Op})
 	}
 }
 
+// applyTime 返回日志的应用时间：优先使用 Leader 追加日志的时间，其次使用命令封装携带的时间。
+// 旧版本写入的日志两者皆无，此时沿用时间水位，避免 TTL 与自动注销按零值时间计算而立即过期；
+// 水位只由日志与快照决定，回放结果仍然确定。
+func (f *raftFSM) applyTime(l *hraft.Log, env commandEnvelope) time.Time {
+	now := l.AppendedAt
+	if now.IsZero() && env.At != 0 {
+		now = time.Unix(0, env.At).UTC()
+	}
+	if now.IsZero() {
+		return f.clock
+	}
+	if now.After(f.clock) {
+		f.clock = now
+	}
+	return now
+}
+
 // Snapshot 将内存状态全量序列化
 func (f *raftFSM) Snapshot() (hraft.FSMSnapshot, error) {
 	f.mu.Lock()
@@ -64,18 +126,38 @@ func (f *raftFSM) Snapshot() (hraft.FSMSnapshot, error) {
 	f.mem.mu.RLock()
 	defer f.mem.mu.RUnlock()
 
-	// 构造快照视图（仅必要��段）
+	// 构造快照视图（仅必要字段）
 	snap := snapshotData{
-		Instances: make(map[string]ServiceInstance, len(f.mem.instances)),
-		Checks:    make(map[string]Check, len(f.mem.checks)),
-		IDToKeys:  make(map[string][]string, len(f.mem.idToKeys)),
-		SvcIndex:  make(map[string]uint64, len(f.mem.svcIndex)),
-		Index:     f.mem.index,
+		Version:          snapshotVersion,
+		Instances:        make(map[string]snapshotInstance, len(f.mem.instances)),
+		Checks:           make(map[string]Check, len(f.mem.checks)),
+		IDToKeys:         make(map[string][]string, len(f.mem.idToKeys)),
+		SvcIndex:         make(map[string]uint64, len(f.mem.svcIndex)),
+		NsIndex:          make(map[string]uint64, len(f.mem.nsIndex)),
+		Servers:          make(map[string]string, len(f.mem.servers)),
+		Nodes:            make(map[string]snapshotNode, len(f.mem.nodes)),
+		Namespaces:       make(map[string]Namespace, len(f.mem.namespaces)),
+		KV:               make(map[string]KVEntry, len(f.mem.kv)),
+		KVTombs:          make(map[string]uint64, len(f.mem.kvTombstones)),
+		Sessions:         make(map[string]Session, len(f.mem.sessions)),
+		LockDelays:       make(map[string]time.Time, len(f.mem.lockDelays)),
+		Queries:          make(map[string]PreparedQuery, len(f.mem.queries)),
+		ServiceDefaults:  make(map[string]ServiceDefaults, len(f.mem.serviceDefaults)),
+		Intentions:       make(map[string]Intention, len(f.mem.intentions)),
+		ServiceSplitters: make(map[string]ServiceSplitter, len(f.mem.serviceSplitters)),
+		KVReapIndex:      f.mem.kvReapIndex,
+		Index:            f.mem.index,
+		AppliedAt:        f.clock,
 	}
 
 	// 复制数据
 	for k, rec := range f.mem.instances {
-		snap.Instances[k] = rec.inst
+		snap.Instances[k] = snapshotInstance{
+			Inst:        rec.inst,
+			CreateIndex: rec.inst.CreateIndex,
+			ModifyIndex: rec.inst.ModifyIndex,
+			Checks:      append([]string(nil), rec.checks...),
+		}
 	}
 	for k, cr := range f.mem.checks {
 		snap.Checks[k] = cr.chk
@@ -86,9 +168,56 @@ func (f *raftFSM) Snapshot() (hraft.FSMSnapshot, error) {
 	for k, v := range f.mem.svcIndex {
 		snap.SvcIndex[k] = v
 	}
+	for k, v := range f.mem.nsIndex {
+		snap.NsIndex[k] = v
+	}
+	for k, v := range f.mem.servers {
+		snap.Servers[k] = v
+	}
+	for k, rec := range f.mem.nodes {
+		snap.Nodes[k] = snapshotNode{Node: rec.node, Checks: append([]string(nil), rec.checks...)}
+	}
+	for k, ns := range f.mem.namespaces {
+		snap.Namespaces[k] = *ns
+	}
+	if f.mem.namespaceConfig != (NamespaceConfig{}) {
+		c := f.mem.namespaceConfig
+		snap.NamespaceConfig = &c
+	}
+	for k, e := range f.mem.kv {
+		snap.KV[k] = *e
+	}
+	for k, v := range f.mem.kvTombstones {
+		snap.KVTombs[k] = v
+	}
+	for k, sess := range f.mem.sessions {
+		snap.Sessions[k] = cloneSession(*sess)
+	}
+	for k, v := range f.mem.lockDelays {
+		snap.LockDelays[k] = v
+	}
+	for k, q := range f.mem.queries {
+		snap.Queries[k] = clonePreparedQuery(*q)
+	}
+	for k, d := range f.mem.serviceDefaults {
+		snap.ServiceDefaults[k] = cloneServiceDefaults(*d)
+	}
+	for k, ix := range f.mem.intentions {
+		snap.Intentions[k] = *ix
+	}
+	if f.mem.intentionConfig != (IntentionConfig{}) {
+		c := f.mem.intentionConfig
+		snap.IntentionConfig = &c
+	}
+	for k, s := range f.mem.serviceSplitters {
+		snap.ServiceSplitters[k] = cloneServiceSplitter(*s)
+	}
 
 	// watchers 不入快照
-	data, _ := json.Marshal(snap)
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
 	return &memSnapshot{data: data}, nil
 }
 
@@ -96,42 +225,150 @@ func (f *raftFSM) Snapshot() (hraft.FSMSnapshot, error) {
 func (f *raftFSM) Restore(rc io.ReadCloser) error {
 	defer rc.Close()
 
-	var snap snapshotData
-	if err := json.NewDecoder(rc).Decode(&snap); err != nil {
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	snap, err := decodeSnapshot(raw)
+	if err != nil {
 		return err
 	}
 
 	f.mem.mu.Lock()
 	defer f.mem.mu.Unlock()
 
-	// 重建实例映射
+	// 唤醒所有阻塞查询：恢复后索引与数据整体替换，等待方须在锁释放后重新读取
+	f.mem.closeWatchersLocked()
+
+	// 重建实例映射（含实例与检查的关联）
 	f.mem.instances = make(map[string]*instanceRecord, len(snap.Instances))
-	for k, inst := range snap.Instances {
-		f.mem.instances[k] = &instanceRecord{inst: inst}
+	for k, si := range snap.Instances {
+		inst := si.Inst
+		inst.CreateIndex = si.CreateIndex
+		inst.ModifyIndex = si.ModifyIndex
+		f.mem.instances[k] = &instanceRecord{inst: inst, checks: append([]string(nil), si.Checks...)}
 	}
 
-	// 重建检查映射
+	// 重建检查映射；时长字段不入 JSON，需从原始字符串恢复
 	f.mem.checks = make(map[string]*checkRecord, len(snap.Checks))
 	for k, c := range snap.Checks {
+		c.Spec = normalizeSpec(c.Spec)
 		f.mem.checks[k] = &checkRecord{chk: c}
 	}
 
-	// 重建 ID 索引
-	f.mem.idToKeys = make(map[string][]string, len(snap.IDToKeys))
-	for k, v := range snap.IDToKeys {
-		f.mem.idToKeys[k] = append([]string(nil), v...)
+	// 重建节点
+	f.mem.nodes = make(map[string]*nodeRecord, len(snap.Nodes))
+	for k, sn := range snap.Nodes {
+		f.mem.nodes[k] = &nodeRecord{node: sn.Node, checks: append([]string(nil), sn.Checks...)}
 	}
 
+	// 重建 ID 索引及二级索引（服务->实例、命名空间->服务、检查->实例、节点->实例）
+	f.mem.rebuildIndexesLocked()
+
 	// 重建服务索引
 	f.mem.svcIndex = make(map[string]uint64, len(snap.SvcIndex))
 	for k, v := range snap.SvcIndex {
 		f.mem.svcIndex[k] = v
 	}
 
+	// 重建命名空间索引；旧快照不含该字段时取命名空间下服务索引的最大值
+	f.mem.nsIndex = make(map[string]uint64, len(snap.NsIndex))
+	for k, v := range snap.NsIndex {
+		f.mem.nsIndex[k] = v
+	}
+	if len(snap.NsIndex) == 0 {
+		for ns, svcs := range f.mem.nsServices {
+			for svc := range svcs {
+				if v := f.mem.svcIndex[f.mem.svcKey(ns, svc)]; v > f.mem.nsIndex[ns] {
+					f.mem.nsIndex[ns] = v
+				}
+			}
+		}
+	}
+
+	// 重建节点地址
+	f.mem.servers = make(map[string]string, len(snap.Servers))
+	for k, v := range snap.Servers {
+		f.mem.servers[k] = v
+	}
+
+	// 重建命名空间
+	f.mem.namespaces = make(map[string]*Namespace, len(snap.Namespaces))
+	for k, ns := range snap.Namespaces {
+		ns := ns
+		f.mem.namespaces[k] = &ns
+	}
+	f.mem.namespaceConfig = NamespaceConfig{}
+	if snap.NamespaceConfig != nil {
+		f.mem.namespaceConfig = *snap.NamespaceConfig
+	}
+
+	// 重建键值存储
+	f.mem.kv = make(map[string]*KVEntry, len(snap.KV))
+	for k, e := range snap.KV {
+		e := e
+		f.mem.kv[k] = &e
+	}
+	f.mem.kvTombstones = make(map[string]uint64, len(snap.KVTombs))
+	for k, v := range snap.KVTombs {
+		f.mem.kvTombstones[k] = v
+	}
+	f.mem.kvReapIndex = snap.KVReapIndex
+
+	// 重建会话与 lock-delay；时长字段不入 JSON，需从原始字符串恢复
+	f.mem.sessions = make(map[string]*Session, len(snap.Sessions))
+	for k, sess := range snap.Sessions {
+		sess, _ = normalizeSession(sess)
+		f.mem.sessions[k] = &sess
+	}
+	f.mem.lockDelays = make(map[string]time.Time, len(snap.LockDelays))
+	for k, v := range snap.LockDelays {
+		f.mem.lockDelays[k] = v
+	}
+
+	// 重建截止时间索引与 检查->会话 索引（依赖检查与会话均已恢复）
+	f.mem.rebuildDeadlinesLocked()
+
+	// 重建预设查询
+	f.mem.queries = make(map[string]*PreparedQuery, len(snap.Queries))
+	for k, q := range snap.Queries {
+		q := q
+		f.mem.queries[k] = &q
+	}
+
+	// 重建服务级配置项；TTL 需从原始字符串恢复
+	f.mem.serviceDefaults = make(map[string]*ServiceDefaults, len(snap.ServiceDefaults))
+	for k, d := range snap.ServiceDefaults {
+		d, _ = normalizeServiceDefaults(d)
+		f.mem.serviceDefaults[k] = &d
+	}
+
+	// 重建调用规则
+	f.mem.intentions = make(map[string]*Intention, len(snap.Intentions))
+	for k, ix := range snap.Intentions {
+		ix := ix
+		f.mem.intentions[k] = &ix
+	}
+	f.mem.intentionConfig = IntentionConfig{}
+	if snap.IntentionConfig != nil {
+		f.mem.intentionConfig = *snap.IntentionConfig
+	}
+
+	// 重建流量拆分配置
+	f.mem.serviceSplitters = make(map[string]*ServiceSplitter, len(snap.ServiceSplitters))
+	for k, s := range snap.ServiceSplitters {
+		s := s
+		f.mem.serviceSplitters[k] = &s
+	}
+
 	f.mem.index = snap.Index
+	f.mem.healthIndex = snap.Index
 
-	// watchers 清空
-	f.mem.watchers = make(map[string][]chan struct{})
+	// 恢复时间水位；旧快照未保存时取状态中最晚的时间
+	f.clock = snap.AppliedAt
+	if f.clock.IsZero() {
+		f.clock = f.mem.latestTimeLocked()
+	}
 
 	return nil
 }
@@ -141,13 +378,13 @@ func (f *raftFSM) Restore(rc io.ReadCloser) error {
 // ============================================================================
 
 // applyRegister 处理注册命令
-func (f *raftFSM) applyRegister(data json.RawMessage) interface{} {
+func (f *raftFSM) applyRegister(data json.RawMessage, now time.Time) interface{} {
 	var cmd registerCommand
 	if err := json.Unmarshal(data, &cmd); err != nil {
 		return encodeResponse(indexResponse{Err: err.Error()})
 	}
 
-	idx, checkIDs, err := f.mem.RegisterInstance(context.TODO(), cmd.Inst, cmd.Specs)
+	idx, checkIDs, err := f.mem.registerAt(cmd.Inst, cmd.Specs, now)
 	if err != nil {
 		return encodeResponse(registerResponse{
 			Index:    idx,
@@ -163,13 +400,13 @@ func (f *raftFSM) applyRegister(data json.RawMessage) interface{} {
 }
 
 // applyDeregister 处理注销命令
-func (f *raftFSM) applyDeregister(data json.RawMessage) interface{} {
+func (f *raftFSM) applyDeregister(data json.RawMessage, now time.Time) interface{} {
 	var cmd deregisterCommand
 	if err := json.Unmarshal(data, &cmd); err != nil {
 		return encodeResponse(indexResponse{Err: err.Error()})
 	}
 
-	idx, err := f.mem.DeregisterInstance(context.TODO(), cmd.Namespace, cmd.Service, cmd.ID)
+	idx, err := f.mem.deregisterAt(cmd.Namespace, cmd.Service, cmd.ID, now)
 	if err != nil {
 		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
 	}
@@ -178,13 +415,13 @@ func (f *raftFSM) applyDeregister(data json.RawMessage) interface{} {
 }
 
 // applyRenewTTL 处理 TTL 续约命令
-func (f *raftFSM) applyRenewTTL(data json.RawMessage) interface{} {
+func (f *raftFSM) applyRenewTTL(data json.RawMessage, now time.Time) interface{} {
 	var cmd checkCommand
 	if err := json.Unmarshal(data, &cmd); err != nil {
 		return encodeResponse(indexResponse{Err: err.Error()})
 	}
 
-	idx, err := f.mem.RenewTTL(context.TODO(), cmd.ID)
+	idx, err := f.mem.renewTTLAt(cmd.ID, now)
 	if err != nil {
 		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
 	}
@@ -193,14 +430,295 @@ func (f *raftFSM) applyRenewTTL(data json.RawMessage) interface{} {
 }
 
 // applyReportCheck 处理健康检查报告命令
-func (f *raftFSM) applyReportCheck(data json.RawMessage) interface{} {
+func (f *raftFSM) applyReportCheck(data json.RawMessage, now time.Time) interface{} {
 	var cmd checkCommand
 	if err := json.Unmarshal(data, &cmd); err != nil {
 		return encodeResponse(indexResponse{Err: err.Error()})
 	}
 
 	status := parseStatus(cmd.Status)
-	idx, err := f.mem.ReportCheck(context.TODO(), cmd.ID, status, cmd.Output)
+	idx, err := f.mem.reportCheckAt(cmd.ID, status, cmd.Output, cmd.Note, now)
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyExpireChecks 处理 TTL 过期命令
+func (f *raftFSM) applyExpireChecks(data json.RawMessage, now time.Time) interface{} {
+	var cmd expireChecksCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx := f.mem.expireChecksAt(cmd.CheckIDs, now)
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyMaintenance 处理维护模式命令
+func (f *raftFSM) applyMaintenance(data json.RawMessage, now time.Time) interface{} {
+	var cmd maintenanceCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx, err := f.mem.setMaintenanceAt(cmd.Namespace, cmd.Service, cmd.ID, cmd.Enable, cmd.Reason, now)
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyReapCritical 处理自动注销命令
+func (f *raftFSM) applyReapCritical(data json.RawMessage, now time.Time) interface{} {
+	var cmd reapCriticalCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx := f.mem.reapInstancesAt(cmd.Keys, now)
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applySetServer 处理发布节点 HTTP 地址命令
+func (f *raftFSM) applySetServer(data json.RawMessage) interface{} {
+	var cmd serverCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx := f.mem.setServerAddr(cmd.ID, cmd.HTTPAddr)
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyRegisterNode 处理注册节点命令
+func (f *raftFSM) applyRegisterNode(data json.RawMessage, now time.Time) interface{} {
+	var cmd registerNodeCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx, checkIDs, err := f.mem.registerNodeAt(cmd.Node, cmd.Specs, now)
+	if err != nil {
+		return encodeResponse(registerResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(registerResponse{
+		Index:    idx,
+		CheckIDs: checkIDs,
+	})
+}
+
+// applyDeregisterNode 处理注销节点命令
+func (f *raftFSM) applyDeregisterNode(data json.RawMessage, now time.Time) interface{} {
+	var cmd deregisterNodeCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx, err := f.mem.deregisterNodeAt(cmd.Name, now)
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyNamespace 处理创建/更新/删除命名空间命令
+func (f *raftFSM) applyNamespace(op string, data json.RawMessage) interface{} {
+	var cmd namespaceCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	var idx uint64
+	var err error
+	if op == opNamespaceDel {
+		idx, err = f.mem.DeleteNamespace(context.TODO(), cmd.Namespace.Name)
+	} else {
+		idx, err = f.mem.UpsertNamespace(context.TODO(), cmd.Namespace)
+	}
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyNamespaceConfig 处理更新命名空间全局配置命令
+func (f *raftFSM) applyNamespaceConfig(data json.RawMessage) interface{} {
+	var cmd namespaceConfigCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx, err := f.mem.SetNamespaceConfig(context.TODO(), cmd.Config)
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyKV 处理键值写操作命令
+func (f *raftFSM) applyKV(data json.RawMessage, now time.Time) interface{} {
+	var cmd kvCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(kvResponse{Err: err.Error()})
+	}
+
+	ok, idx, err := f.mem.kvApplyAt(cmd.Op, now)
+	if err != nil {
+		return encodeResponse(kvResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(kvResponse{Index: idx, OK: ok})
+}
+
+// applySession 处理创建/销毁/续约会话命令
+func (f *raftFSM) applySession(op string, data json.RawMessage, now time.Time) interface{} {
+	var cmd sessionCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(sessionResponse{Err: err.Error()})
+	}
+
+	var s Session
+	var idx uint64
+	var err error
+	switch op {
+	case opSessionCreate:
+		s, idx, err = f.mem.createSessionAt(cmd.Session, now)
+	case opSessionRenew:
+		s, idx, err = f.mem.renewSessionAt(cmd.Session.ID, now)
+	default:
+		idx, err = f.mem.destroySessionAt(cmd.Session.ID, now)
+	}
+	if err != nil {
+		return encodeResponse(sessionResponse{Index: idx, Err: err.Error()})
+	}
+	if op == opSessionDestroy {
+		return encodeResponse(sessionResponse{Index: idx})
+	}
+
+	return encodeResponse(sessionResponse{Index: idx, Session: &s})
+}
+
+// applyExpireSessions 处理会话过期命令
+func (f *raftFSM) applyExpireSessions(data json.RawMessage, now time.Time) interface{} {
+	var cmd expireSessionsCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx := f.mem.expireSessionsAt(cmd.IDs, now)
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyReapTombstones 处理键值墓碑回收命令
+func (f *raftFSM) applyReapTombstones(data json.RawMessage) interface{} {
+	var cmd reapTombstonesCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx := f.mem.reapTombstones(cmd.Index)
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyQuery 处理创建/更新/删除预设查询命令
+func (f *raftFSM) applyQuery(op string, data json.RawMessage) interface{} {
+	var cmd queryCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	var idx uint64
+	var err error
+	if op == opQueryDel {
+		idx, err = f.mem.DeletePreparedQuery(context.TODO(), cmd.Query.Name)
+	} else {
+		idx, err = f.mem.UpsertPreparedQuery(context.TODO(), cmd.Query)
+	}
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyConfig 处理创建/更新/删除服务级配置项命令
+func (f *raftFSM) applyConfig(op string, data json.RawMessage) interface{} {
+	var cmd configCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	var idx uint64
+	var err error
+	if op == opConfigDel {
+		idx, err = f.mem.DeleteServiceDefaults(context.TODO(), cmd.Defaults.Namespace, cmd.Defaults.Name)
+	} else {
+		idx, err = f.mem.UpsertServiceDefaults(context.TODO(), cmd.Defaults)
+	}
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyIntention 处理创建/更新/删除调用规则命令
+func (f *raftFSM) applyIntention(op string, data json.RawMessage) interface{} {
+	var cmd intentionCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	var idx uint64
+	var err error
+	if op == opIntentionDel {
+		idx, err = f.mem.DeleteIntention(context.TODO(), cmd.Intention.ID)
+	} else {
+		_, idx, err = f.mem.UpsertIntention(context.TODO(), cmd.Intention)
+	}
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applyIntentionConfig 处理更新调用规则全局配置命令
+func (f *raftFSM) applyIntentionConfig(data json.RawMessage) interface{} {
+	var cmd intentionConfigCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	idx, err := f.mem.SetIntentionConfig(context.TODO(), cmd.Config)
+	if err != nil {
+		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
+	}
+
+	return encodeResponse(indexResponse{Index: idx})
+}
+
+// applySplitter 处理创建/更新/删除流量拆分配置命令
+func (f *raftFSM) applySplitter(op string, data json.RawMessage) interface{} {
+	var cmd splitterCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return encodeResponse(indexResponse{Err: err.Error()})
+	}
+
+	var idx uint64
+	var err error
+	if op == opSplitterDel {
+		idx, err = f.mem.DeleteServiceSplitter(context.TODO(), cmd.Splitter.Namespace, cmd.Splitter.Name)
+	} else {
+		idx, err = f.mem.UpsertServiceSplitter(context.TODO(), cmd.Splitter)
+	}
 	if err != nil {
 		return encodeResponse(indexResponse{Index: idx, Err: err.Error()})
 	}
@@ -212,8 +730,53 @@ func (f *raftFSM) applyReportCheck(data json.RawMessage) interface{} {
 // 快照相关类型
 // ============================================================================
 
-// snapshotData 快照数据结构
+// snapshotVersion 为当前快照格式版本。
+// v1：无版本头，实例不含检查列表（恢复后检查关联丢失）；
+// v2：增加版本头，实例携带检查列表及创建/修改索引。
+const snapshotVersion = 2
+
+// snapshotData 快照数据结构（v2）
 type snapshotData struct {
+	Version          int                         `json:"version"`
+	Instances        map[string]snapshotInstance `json:"instances"`
+	Checks           map[string]Check            `json:"checks"`
+	IDToKeys         map[string][]string         `json:"id_to_keys"`
+	SvcIndex         map[string]uint64           `json:"svc_index"`
+	NsIndex          map[string]uint64           `json:"ns_index,omitempty"`
+	Servers          map[string]string           `json:"servers,omitempty"`
+	Nodes            map[string]snapshotNode     `json:"nodes,omitempty"`
+	Namespaces       map[string]Namespace        `json:"namespaces,omitempty"`
+	NamespaceConfig  *NamespaceConfig            `json:"namespace_config,omitempty"`
+	KV               map[string]KVEntry          `json:"kv,omitempty"`
+	KVTombs          map[string]uint64           `json:"kv_tombstones,omitempty"`
+	KVReapIndex      uint64                      `json:"kv_reap_index,omitempty"`
+	Sessions         map[string]Session          `json:"sessions,omitempty"`
+	LockDelays       map[string]time.Time        `json:"lock_delays,omitempty"`
+	Queries          map[string]PreparedQuery    `json:"queries,omitempty"`
+	ServiceDefaults  map[string]ServiceDefaults  `json:"service_defaults,omitempty"`
+	Intentions       map[string]Intention        `json:"intentions,omitempty"`
+	IntentionConfig  *IntentionConfig            `json:"intention_config,omitempty"`
+	ServiceSplitters map[string]ServiceSplitter  `json:"service_splitters,omitempty"`
+	Index            uint64                      `json:"index"`
+	AppliedAt        time.Time                   `json:"applied_at"` // 时间水位（见 raftFSM.clock）
+}
+
+// snapshotInstance 快照中的实例记录
+type snapshotInstance struct {
+	Inst        ServiceInstance `json:"inst"`
+	CreateIndex uint64          `json:"create_index"`
+	ModifyIndex uint64          `json:"modify_index"`
+	Checks      []string        `json:"checks"`
+}
+
+// snapshotNode 快照中的节点记录
+type snapshotNode struct {
+	Node   Node     `json:"node"`
+	Checks []string `json:"checks"`
+}
+
+// snapshotDataV1 为 v1 快照结构，仅用于迁移
+type snapshotDataV1 struct {
 	Instances map[string]ServiceInstance `json:"instances"`
 	Checks    map[string]Check           `json:"checks"`
 	IDToKeys  map[string][]string        `json:"id_to_keys"`
@@ -221,6 +784,90 @@ type snapshotData struct {
 	Index     uint64                     `json:"index"`
 }
 
+// decodeSnapshot 按版本头解码快照，旧版本会迁移为当前格式
+func decodeSnapshot(raw []byte) (snapshotData, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return snapshotData{}, err
+	}
+
+	switch header.Version {
+	case 0, 1:
+		var v1 snapshotDataV1
+		if err := json.Unmarshal(raw, &v1); err != nil {
+			return snapshotData{}, err
+		}
+		return migrateSnapshotV1(v1), nil
+	case snapshotVersion:
+		var snap snapshotData
+		if err := json.Unmarshal(raw, &snap); err != nil {
+			return snapshotData{}, err
+		}
+		return snap, nil
+	default:
+		return snapshotData{}, fmt.Errorf("unsupported snapshot version: %d", header.Version)
+	}
+}
+
+// migrateSnapshotV1 将 v1 快照迁移为 v2。
+// v1 未保存实例的检查列表，依据检查 ID 的命名规则 chk:{instanceID}:{n} 还原关联；
+// 创建/修改索引同样缺失，以服务索引近似。
+func migrateSnapshotV1(v1 snapshotDataV1) snapshotData {
+	snap := snapshotData{
+		Version:   snapshotVersion,
+		Instances: make(map[string]snapshotInstance, len(v1.Instances)),
+		Checks:    v1.Checks,
+		IDToKeys:  v1.IDToKeys,
+		SvcIndex:  v1.SvcIndex,
+		Index:     v1.Index,
+	}
+
+	// instanceID -> [(序号, checkID)]
+	type ordered struct {
+		n   int
+		cid string
+	}
+	byInst := make(map[string][]ordered)
+	for cid := range v1.Checks {
+		instID, n, ok := parseCheckID(cid)
+		if !ok {
+			continue
+		}
+		byInst[instID] = append(byInst[instID], ordered{n: n, cid: cid})
+	}
+
+	for k, inst := range v1.Instances {
+		lst := byInst[inst.ID]
+		sort.Slice(lst, func(i, j int) bool { return lst[i].n < lst[j].n })
+		var checks []string
+		for _, o := range lst {
+			checks = append(checks, o.cid)
+		}
+		idx := v1.SvcIndex[inst.Namespace+"/"+inst.Service]
+		snap.Instances[k] = snapshotInstance{Inst: inst, CreateIndex: idx, ModifyIndex: idx, Checks: checks}
+	}
+	return snap
+}
+
+// parseCheckID 解析 chk:{instanceID}:{n} 形式的检查 ID
+func parseCheckID(cid string) (instID string, n int, ok bool) {
+	if !strings.HasPrefix(cid, "chk:") {
+		return "", 0, false
+	}
+	rest := strings.TrimPrefix(cid, "chk:")
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(rest[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return rest[:i], n, true
+}
+
 // memSnapshot 实现 hraft.FSMSnapshot 接口
 type memSnapshot struct {
 	data []byte
@@ -0,0 +1,462 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	hraft "github.com/hashicorp/raft"
+)
+
+// bufferSink 是写入内存的 hraft.SnapshotSink
+type bufferSink struct {
+	bytes.Buffer
+}
+
+func (s *bufferSink) ID() string    { return "test" }
+func (s *bufferSink) Cancel() error { return nil }
+func (s *bufferSink) Close() error  { return nil }
+
+// persistSnapshot 对 FSM 做快照并返回持久化后的字节
+func persistSnapshot(t *testing.T, f *raftFSM) []byte {
+	t.Helper()
+	snap, err := f.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	var sink bufferSink
+	if err := snap.Persist(&sink); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	snap.Release()
+	return sink.Bytes()
+}
+
+func restoreSnapshot(f *raftFSM, data []byte) error {
+	return f.Restore(io.NopCloser(bytes.NewReader(data)))
+}
+
+// populatedRegistry 构造覆盖所有快照字段的注册表
+func populatedRegistry(t *testing.T) *memoryRegistry {
+	t.Helper()
+	ctx := context.Background()
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0).UTC()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := m.UpsertNamespace(ctx, Namespace{Name: "team-a", Description: "a", Quota: NamespaceQuota{MaxInstances: 10}})
+	must(err)
+	_, err = m.SetNamespaceConfig(ctx, NamespaceConfig{Strict: true})
+	must(err)
+	_, _, err = m.registerNodeAt(Node{Name: "node1", Address: "10.0.0.1", Meta: map[string]string{"rack": "r1"}},
+		[]CheckSpec{{Type: CheckTTL, TTLRaw: "30s"}}, t0)
+	must(err)
+	_, err = m.UpsertServiceDefaults(ctx, ServiceDefaults{Namespace: "team-a", Name: "web", Protocol: "http", TTLRaw: "20s"})
+	must(err)
+
+	specs := []CheckSpec{
+		{Type: CheckTTL, TTLRaw: "15s", DCARaw: "10m", SuccessBeforePassing: 2},
+		{Type: CheckTTL, TTLRaw: "1m"},
+	}
+	_, ids, err := m.registerAt(ServiceInstance{Namespace: "team-a", Service: "web", ID: "web-1", Address: "10.0.0.2", Port: 80,
+		Node: "node1", Tags: []string{"v1"}, Meta: map[string]string{"version": "1"}}, specs, t0)
+	must(err)
+	_, _, err = m.registerAt(ServiceInstance{Namespace: "team-a", Service: "web", ID: "web-2", Address: "10.0.0.3", Port: 80,
+		Tags: []string{"v2"}}, nil, t0)
+	must(err)
+	_, _, err = m.registerAt(ServiceInstance{Service: "api", ID: "api-1", Address: "10.0.0.4", Port: 90}, nil, t0)
+	must(err)
+	_, err = m.renewTTLAt(ids[1], t0.Add(time.Second))
+	must(err)
+	_, err = m.reportCheckAt(ids[0], StatusWarning, "slow", "note", t0.Add(2*time.Second))
+	must(err)
+	_, err = m.setMaintenanceAt("", "", "api-1", true, "upgrade", t0.Add(3*time.Second))
+	must(err)
+	m.setServerAddr("n1", "127.0.0.1:8500")
+
+	_, _, err = m.kvApplyAt(KVOp{Verb: KVSet, Key: "app/config", Value: []byte("v"), Flags: 7}, t0)
+	must(err)
+	_, _, err = m.kvApplyAt(KVOp{Verb: KVSet, Key: "app/old", Value: []byte("x")}, t0)
+	must(err)
+	_, _, err = m.kvApplyAt(KVOp{Verb: KVSet, Key: "app/gone", Value: []byte("x")}, t0)
+	must(err)
+	_, _, err = m.kvApplyAt(KVOp{Verb: KVDelete, Key: "app/gone"}, t0)
+	must(err)
+	m.reapTombstones(m.index) // app/gone 的墓碑已回收，仅留 kvReapIndex
+	_, _, err = m.kvApplyAt(KVOp{Verb: KVDelete, Key: "app/old"}, t0)
+	must(err)
+
+	// 会话 s1 持有锁；s2 加锁后销毁，留下 lock-delay
+	_, _, err = m.createSessionAt(Session{ID: "s1", Name: "leader", Checks: []string{ids[1]}, TTLRaw: "30s", LockDelayRaw: "15s"}, t0)
+	must(err)
+	_, _, err = m.kvApplyAt(KVOp{Verb: KVLock, Key: "locks/leader", Value: []byte("n1"), Session: "s1"}, t0)
+	must(err)
+	_, _, err = m.createSessionAt(Session{ID: "s2", Behavior: SessionDelete, LockDelayRaw: "30s"}, t0)
+	must(err)
+	_, _, err = m.kvApplyAt(KVOp{Verb: KVLock, Key: "locks/other", Session: "s2"}, t0)
+	must(err)
+	_, err = m.destroySessionAt("s2", t0)
+	must(err)
+
+	_, err = m.UpsertPreparedQuery(ctx, PreparedQuery{Name: "web-query", Namespace: "team-a", Service: "web", Tag: "v1",
+		OnlyPassing: true, Failover: []string{"default"}})
+	must(err)
+	_, _, err = m.UpsertIntention(ctx, Intention{SourceNS: "default", SourceName: "api", DestinationNS: "team-a",
+		DestinationName: "web", Action: IntentionAllow, Description: "api to web"})
+	must(err)
+	_, err = m.SetIntentionConfig(ctx, IntentionConfig{DefaultDeny: true})
+	must(err)
+	_, err = m.UpsertServiceSplitter(ctx, ServiceSplitter{Namespace: "team-a", Name: "web",
+		Subsets: []ServiceSubset{{Name: "v1", Tag: "v1"}, {Name: "v2", Tag: "v2"}},
+		Splits:  []ServiceSplit{{Subset: "v1", Percent: 90}, {Subset: "v2", Percent: 10}}})
+	must(err)
+	return m
+}
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	src := populatedRegistry(t)
+	srcFSM := NewRaftFSMForServer(src)
+	srcFSM.clock = src.latestTimeLocked() // 状态未经 Apply 构造，手动设置时间水位
+	data := persistSnapshot(t, srcFSM)
+
+	dst := NewMemoryRegistryWithOptions(Options{})
+	f := NewRaftFSMForServer(dst)
+	if err := restoreSnapshot(f, data); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	// 恢复后的状态再次快照应与原快照一致
+	if again := persistSnapshot(t, f); !bytes.Equal(data, again) {
+		t.Fatalf("snapshot after restore differs:\n%s\n%s", data, again)
+	}
+
+	// 不入 JSON 的时长字段须从原始字符串恢复，直接比较内存结构
+	for _, tc := range []struct {
+		name      string
+		got, want any
+	}{
+		{"instances", dst.instances, src.instances},
+		{"checks", dst.checks, src.checks},
+		{"nodes", dst.nodes, src.nodes},
+		{"namespaces", dst.namespaces, src.namespaces},
+		{"namespace config", dst.namespaceConfig, src.namespaceConfig},
+		{"kv", dst.kv, src.kv},
+		{"kv tombstones", dst.kvTombstones, src.kvTombstones},
+		{"kv reap index", dst.kvReapIndex, src.kvReapIndex},
+		{"sessions", dst.sessions, src.sessions},
+		{"lock delays", dst.lockDelays, src.lockDelays},
+		{"queries", dst.queries, src.queries},
+		{"service defaults", dst.serviceDefaults, src.serviceDefaults},
+		{"intentions", dst.intentions, src.intentions},
+		{"intention config", dst.intentionConfig, src.intentionConfig},
+		{"service splitters", dst.serviceSplitters, src.serviceSplitters},
+		{"servers", dst.servers, src.servers},
+		{"svc index", dst.svcIndex, src.svcIndex},
+		{"ns index", dst.nsIndex, src.nsIndex},
+		{"index", dst.index, src.index},
+		{"clock", f.clock, srcFSM.clock},
+		// 二级索引与截止时间索引由恢复重建
+		{"svc instances", dst.svcInstances, src.svcInstances},
+		{"ns services", dst.nsServices, src.nsServices},
+		{"check owner", dst.checkOwner, src.checkOwner},
+		{"check node", dst.checkNode, src.checkNode},
+		{"node instances", dst.nodeInstances, src.nodeInstances},
+		{"check sessions", dst.checkSessions, src.checkSessions},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%s mismatch:\n got  %#v\n want %#v", tc.name, tc.got, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		name      string
+		got, want deadlineIndex
+	}{
+		{"ttl deadlines", dst.ttlDeadlines, src.ttlDeadlines},
+		{"reap deadlines", dst.reapDeadlines, src.reapDeadlines},
+		{"session deadlines", dst.sessionDeadlines, src.sessionDeadlines},
+	} {
+		if got, want := deadlineMap(tc.got), deadlineMap(tc.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s mismatch: got %v, want %v", tc.name, got, want)
+		}
+	}
+}
+
+// deadlineMap 将截止时间堆转为 ID -> 截止时间，忽略堆内顺序
+func deadlineMap(d deadlineIndex) map[string]time.Time {
+	out := make(map[string]time.Time, len(d.items))
+	for _, e := range d.items {
+		out[e.id] = e.at
+	}
+	return out
+}
+
+func TestRestoreMigratesV1Snapshot(t *testing.T) {
+	t0 := time.Unix(1700000000, 0).UTC()
+	v1 := snapshotDataV1{
+		Instances: map[string]ServiceInstance{
+			"default/web/web-1": {Namespace: "default", Service: "web", ID: "web-1", Address: "10.0.0.1", Port: 80},
+			"default/web/a:b":   {Namespace: "default", Service: "web", ID: "a:b", Address: "10.0.0.2", Port: 80},
+			"default/api/api-1": {Namespace: "default", Service: "api", ID: "api-1", Address: "10.0.0.3", Port: 90},
+		},
+		Checks: map[string]Check{
+			"chk:web-1:10": {ID: "chk:web-1:10", Spec: CheckSpec{Type: CheckTTL, TTLRaw: "10s"}, Status: StatusPassing, LastUpdate: t0},
+			"chk:web-1:2":  {ID: "chk:web-1:2", Spec: CheckSpec{Type: CheckTTL, TTLRaw: "10s"}, Status: StatusPassing, LastUpdate: t0},
+			"chk:web-1:0":  {ID: "chk:web-1:0", Spec: CheckSpec{Type: CheckTTL, TTLRaw: "10s"}, Status: StatusCritical, LastUpdate: t0},
+			"chk:a:b:0":    {ID: "chk:a:b:0", Spec: CheckSpec{Type: CheckTTL, TTLRaw: "20s"}, Status: StatusPassing, LastUpdate: t0},
+			"custom":       {ID: "custom", Status: StatusPassing, LastUpdate: t0},
+		},
+		IDToKeys: map[string][]string{
+			"web-1": {"default/web/web-1"},
+			"a:b":   {"default/web/a:b"},
+			"api-1": {"default/api/api-1"},
+		},
+		SvcIndex: map[string]uint64{"default/web": 5, "default/api": 3},
+		Index:    5,
+	}
+	raw, err := json.Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMemoryRegistryWithOptions(Options{})
+	if err := restoreSnapshot(NewRaftFSMForServer(m), raw); err != nil {
+		t.Fatalf("restore v1: %v", err)
+	}
+
+	wantChecks := map[string][]string{
+		"default/web/web-1": {"chk:web-1:0", "chk:web-1:2", "chk:web-1:10"},
+		"default/web/a:b":   {"chk:a:b:0"},
+		"default/api/api-1": nil,
+	}
+	for k, want := range wantChecks {
+		rec, ok := m.instances[k]
+		if !ok {
+			t.Fatalf("instance %s missing after migration", k)
+		}
+		if !reflect.DeepEqual(rec.checks, want) {
+			t.Errorf("%s checks = %v, want %v", k, rec.checks, want)
+		}
+		idx := v1.SvcIndex[m.svcKey(rec.inst.Namespace, rec.inst.Service)]
+		if rec.inst.CreateIndex != idx || rec.inst.ModifyIndex != idx {
+			t.Errorf("%s indexes = %d/%d, want %d", k, rec.inst.CreateIndex, rec.inst.ModifyIndex, idx)
+		}
+	}
+	if got := m.checks["chk:a:b:0"].chk.Spec.TTL; got != 20*time.Second {
+		t.Errorf("migrated check TTL = %v, want 20s", got)
+	}
+	if owner := m.checkOwner["chk:web-1:10"]; owner != "default/web/web-1" {
+		t.Errorf("check owner = %q, want default/web/web-1", owner)
+	}
+	if m.index != 5 {
+		t.Errorf("index = %d, want 5", m.index)
+	}
+
+	// 迁移后的健康状态按检查关联计算：web-1 含 critical 检查，不应出现在 passing 结果中
+	insts, _, err := m.ListHealthyInstances(context.Background(), "default", "web", ListOptions{PassingOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insts) != 1 || insts[0].ID != "a:b" {
+		t.Errorf("passing instances = %v, want [a:b]", insts)
+	}
+}
+
+func TestParseCheckID(t *testing.T) {
+	tests := []struct {
+		cid    string
+		instID string
+		n      int
+		ok     bool
+	}{
+		{"chk:web-1:0", "web-1", 0, true},
+		{"chk:web-1:12", "web-1", 12, true},
+		{"chk:a:b:3", "a:b", 3, true},
+		{"chk::0", "", 0, false},
+		{"chk:web-1", "", 0, false},
+		{"chk:web-1:x", "", 0, false},
+		{"maint:web-1", "", 0, false},
+		{"nodechk:n1:0", "", 0, false},
+	}
+	for _, tt := range tests {
+		instID, n, ok := parseCheckID(tt.cid)
+		if instID != tt.instID || n != tt.n || ok != tt.ok {
+			t.Errorf("parseCheckID(%q) = %q, %d, %v; want %q, %d, %v", tt.cid, instID, n, ok, tt.instID, tt.n, tt.ok)
+		}
+	}
+}
+
+func TestRestoreRejectsUnsupportedVersion(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	if _, _, err := m.registerAt(ServiceInstance{Service: "web", ID: "web-1"}, nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	before := m.index
+
+	err := restoreSnapshot(NewRaftFSMForServer(m), []byte(`{"version":99,"instances":{}}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported snapshot version: 99") {
+		t.Fatalf("restore error = %v, want unsupported snapshot version", err)
+	}
+	// 解码失败时不应改动现有状态
+	if m.index != before || len(m.instances) != 1 {
+		t.Fatalf("state changed after failed restore: index=%d instances=%d", m.index, len(m.instances))
+	}
+}
+
+// applyLogs 按顺序将命令作为日志应用到 FSM，返回各条的响应
+func applyLogs(f *raftFSM, at time.Time, cmds ...[]byte) [][]byte {
+	var out [][]byte
+	for i, data := range cmds {
+		resp := f.Apply(&hraft.Log{Index: uint64(i + 1), Data: data, AppendedAt: at})
+		out = append(out, resp.([]byte))
+	}
+	return out
+}
+
+func TestNamespaceConfigIsReplicated(t *testing.T) {
+	t0 := time.Unix(1700000000, 0).UTC()
+	cfg, _ := BuildNamespaceConfigCommand(NamespaceConfig{Strict: true})
+	reg, _ := BuildRegisterCommand(ServiceInstance{Namespace: "typo", Service: "web", ID: "web-1"}, nil)
+	nsSet, _ := BuildNamespaceSetCommand(Namespace{Name: "typo"})
+
+	// 两个副本应用同一日志，准入结果只取决于日志内容
+	for _, name := range []string{"replica-a", "replica-b"} {
+		f := NewRaftFSMForServer(NewMemoryRegistryWithOptions(Options{}))
+		resps := applyLogs(f, t0, cfg, reg, nsSet, reg)
+		if _, _, err := ParseRegisterResponse(resps[1]); err == nil || !strings.Contains(err.Error(), "namespace not found") {
+			t.Fatalf("%s: register before namespace exists: err = %v, want namespace not found", name, err)
+		}
+		if _, _, err := ParseRegisterResponse(resps[3]); err != nil {
+			t.Fatalf("%s: register after namespace created: %v", name, err)
+		}
+		if c, _, _ := f.mem.GetNamespaceConfig(context.Background()); !c.Strict {
+			t.Fatalf("%s: namespace config not applied", name)
+		}
+	}
+}
+
+// legacyCommand 构建旧版本格式的命令：封装中没有时间字段
+func legacyCommand(t *testing.T, op string, payload any) []byte {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(commandEnvelope{Op: op, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestReplayLegacyLogWithoutTimestamps(t *testing.T) {
+	t0 := time.Unix(1700000000, 0).UTC()
+	spec := CheckSpec{Type: CheckTTL, TTLRaw: "30s", DCARaw: "1m"}
+	// 旧版本（v1）快照：web-1 的检查在 t0 续约
+	v1 := snapshotDataV1{
+		Instances: map[string]ServiceInstance{
+			"default/web/web-1": {Namespace: "default", Service: "web", ID: "web-1"},
+		},
+		Checks: map[string]Check{
+			"chk:web-1:0": {ID: "chk:web-1:0", Spec: spec, Status: StatusPassing, LastUpdate: t0, LastPass: t0},
+		},
+		IDToKeys: map[string][]string{"web-1": {"default/web/web-1"}},
+		SvcIndex: map[string]uint64{"default/web": 1},
+		Index:    1,
+	}
+	snap, err := json.Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本写入的日志：AppendedAt 与封装时间均为零值
+	logs := [][]byte{
+		legacyCommand(t, opRegister, registerCommand{Inst: ServiceInstance{Service: "web", ID: "web-2"}, Specs: []CheckSpec{spec}}),
+		legacyCommand(t, opRenewTTL, checkCommand{ID: "chk:web-2:0"}),
+		legacyCommand(t, opReportCheck, checkCommand{ID: "chk:web-1:0", Status: "fail", Output: "down"}),
+	}
+
+	replay := func() *raftFSM {
+		f := NewRaftFSMForServer(NewMemoryRegistryWithOptions(Options{}))
+		if err := restoreSnapshot(f, snap); err != nil {
+			t.Fatalf("restore: %v", err)
+		}
+		for _, resp := range applyLogs(f, time.Time{}, logs...) {
+			if _, err := ParseIndexResponse(resp); err != nil {
+				t.Fatalf("apply legacy entry: %v", err)
+			}
+		}
+		return f
+	}
+	f := replay()
+	m := f.mem
+
+	// 旧日志沿用快照中的时间水位，而非零值
+	web2 := m.checks["chk:web-2:0"].chk
+	if !web2.LastPass.Equal(t0) || !web2.LastUpdate.Equal(t0) {
+		t.Fatalf("web-2 LastPass/LastUpdate = %v/%v, want %v", web2.LastPass, web2.LastUpdate, t0)
+	}
+	if cs := m.checks["chk:web-1:0"].chk.CriticalSince; !cs.Equal(t0) {
+		t.Fatalf("web-1 CriticalSince = %v, want %v", cs, t0)
+	}
+
+	// 回放后不会立即过期或被注销，超时后照常处理
+	soon := t0.Add(5 * time.Second)
+	m.mu.RLock()
+	expired, reapable := m.expiredChecksLocked(soon), m.reapableInstancesLocked(soon)
+	m.mu.RUnlock()
+	if len(expired) != 0 || len(reapable) != 0 {
+		t.Fatalf("right after replay: expired=%v reapable=%v, want none", expired, reapable)
+	}
+	later := t0.Add(2 * time.Minute)
+	m.mu.RLock()
+	expired, reapable = m.expiredChecksLocked(later), m.reapableInstancesLocked(later)
+	m.mu.RUnlock()
+	if fmt.Sprint(expired) != "[chk:web-2:0]" || fmt.Sprint(reapable) != "[default/web/web-1]" {
+		t.Fatalf("after timeout: expired=%v reapable=%v", expired, reapable)
+	}
+
+	// 回放结果确定：再次回放得到相同快照
+	if a, b := persistSnapshot(t, f), persistSnapshot(t, replay()); !bytes.Equal(a, b) {
+		t.Fatalf("replay is not deterministic:\n%s\n%s", a, b)
+	}
+
+	// 新版本命令即使缺少 AppendedAt，也使用封装携带的时间并推进水位
+	t1 := t0.Add(time.Hour)
+	cmd, _ := BuildRenewTTLCommand("chk:web-2:0")
+	var env commandEnvelope
+	_ = json.Unmarshal(cmd, &env)
+	env.At = t1.UnixNano()
+	cmd, _ = json.Marshal(env)
+	applyLogs(f, time.Time{}, cmd)
+	if lp := m.checks["chk:web-2:0"].chk.LastPass; !lp.Equal(t1) || !f.clock.Equal(t1) {
+		t.Fatalf("LastPass = %v, clock = %v, want %v", lp, f.clock, t1)
+	}
+}
+
+func TestReplayLegacyLogFromEmptyState(t *testing.T) {
+	// 没有快照也没有任何时间信息时，时间相关状态不启动：不会按零值时间立即过期或注销
+	spec := CheckSpec{Type: CheckTTL, TTLRaw: "30s", DCARaw: "1m"}
+	f := NewRaftFSMForServer(NewMemoryRegistryWithOptions(Options{}))
+	applyLogs(f, time.Time{},
+		legacyCommand(t, opRegister, registerCommand{Inst: ServiceInstance{Service: "web", ID: "web-1"}, Specs: []CheckSpec{spec}}),
+		legacyCommand(t, opRenewTTL, checkCommand{ID: "chk:web-1:0"}),
+		legacyCommand(t, opRegister, registerCommand{Inst: ServiceInstance{Service: "web", ID: "web-2"}, Specs: []CheckSpec{spec}}),
+	)
+	now := time.Now()
+	f.mem.mu.RLock()
+	expired, reapable := f.mem.expiredChecksLocked(now), f.mem.reapableInstancesLocked(now)
+	f.mem.mu.RUnlock()
+	if len(expired) != 0 || len(reapable) != 0 {
+		t.Fatalf("expired=%v reapable=%v, want none", expired, reapable)
+	}
+}
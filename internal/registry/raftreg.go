@@ -2,6 +2,8 @@ package registry
 
 import (
 	"context"
+	"log"
+	"sync"
 	"time"
 
 	hraft "github.com/hashicorp/raft"
@@ -18,6 +20,11 @@ import (
 type RaftRegistry struct {
 	raft *hraft.Raft
 	mem  *memoryRegistry
+
+	// TTL 过期器：仅在 Leader 上运行，过期事件以命令形式提交
+	mu             sync.Mutex
+	stopCh         chan struct{}
+	expirerStarted bool
 }
 
 // NewRaftRegistry 创建一个新的 RaftRegistry 实例
@@ -27,9 +34,32 @@ func NewRaftRegistry(r *hraft.Raft, mem *memoryRegistry) *RaftRegistry {
 
 // Stop 停止底层注册表（包括 TTL 过期器）
 func (r *RaftRegistry) Stop() {
+	r.StopExpirer()
 	r.mem.Stop()
 }
 
+// StartExpirer 启动基于 Raft 的 TTL 过期器（若尚未启动），应在成为 Leader 时调用。
+func (r *RaftRegistry) StartExpirer() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.expirerStarted {
+		return
+	}
+	r.stopCh = make(chan struct{})
+	r.expirerStarted = true
+	go r.expirer(r.stopCh)
+}
+
+// StopExpirer 停止 TTL 过期器（若正在运行），应在失去 Leader 身份时调用。
+func (r *RaftRegistry) StopExpirer() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.expirerStarted {
+		close(r.stopCh)
+		r.expirerStarted = false
+	}
+}
+
 // ============================================================================
 // 写操作 - 通过 Raft 提交
 // ============================================================================
@@ -80,8 +110,98 @@ func (r *RaftRegistry) RenewTTL(ctx context.Context, checkID string) (uint64, er
 }
 
 // ReportCheck 报告健康检查结果（写操作，通过 Raft 复制）
-func (r *RaftRegistry) ReportCheck(ctx context.Context, checkID string, status CheckStatus, output string) (uint64, error) {
-	cmdData, err := BuildReportCheckCommand(checkID, status, output)
+func (r *RaftRegistry) ReportCheck(ctx context.Context, checkID string, status CheckStatus, output, note string) (uint64, error) {
+	cmdData, err := BuildReportCheckCommand(checkID, status, output, note)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// SetMaintenance 开启/关闭维护模式（写操作，通过 Raft 复制）
+func (r *RaftRegistry) SetMaintenance(ctx context.Context, namespace, service, id string, enable bool, reason string) (uint64, error) {
+	cmdData, err := BuildMaintenanceCommand(namespace, service, id, enable, reason)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// RegisterNode 注册或更新节点及其节点级检查（写操作，通过 Raft 复制）
+func (r *RaftRegistry) RegisterNode(ctx context.Context, node Node, specs []CheckSpec) (uint64, []string, error) {
+	cmdData, err := BuildRegisterNodeCommand(node, specs)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return ParseRegisterResponse(respData)
+}
+
+// DeregisterNode 注销节点（写操作，通过 Raft 复制）
+func (r *RaftRegistry) DeregisterNode(ctx context.Context, name string) (uint64, error) {
+	cmdData, err := BuildDeregisterNodeCommand(name)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// UpsertNamespace 创建或更新命名空间（写操作，通过 Raft 复制）
+func (r *RaftRegistry) UpsertNamespace(ctx context.Context, ns Namespace) (uint64, error) {
+	cmdData, err := BuildNamespaceSetCommand(ns)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// DeleteNamespace 删除命名空间（写操作，通过 Raft 复制）
+func (r *RaftRegistry) DeleteNamespace(ctx context.Context, name string) (uint64, error) {
+	cmdData, err := BuildNamespaceDeleteCommand(name)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// SetNamespaceConfig 更新命名空间全局配置（写操作，通过 Raft 复制）
+func (r *RaftRegistry) SetNamespaceConfig(ctx context.Context, c NamespaceConfig) (uint64, error) {
+	cmdData, err := BuildNamespaceConfigCommand(c)
 	if err != nil {
 		return 0, err
 	}
@@ -94,6 +214,222 @@ func (r *RaftRegistry) ReportCheck(ctx context.Context, checkID string, status C
 	return ParseIndexResponse(respData)
 }
 
+// KVApply 执行键值写操作（写操作，通过 Raft 复制）
+func (r *RaftRegistry) KVApply(ctx context.Context, op KVOp) (bool, uint64, error) {
+	cmdData, err := BuildKVCommand(op)
+	if err != nil {
+		return false, 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return ParseKVResponse(respData)
+}
+
+// CreateSession 创建会话（写操作，通过 Raft 复制）；ID 在提交前生成，保证各节点一致
+func (r *RaftRegistry) CreateSession(ctx context.Context, s Session) (Session, uint64, error) {
+	if s.ID == "" {
+		s.ID = newUUID()
+	}
+	cmdData, err := BuildSessionCreateCommand(s)
+	if err != nil {
+		return Session{}, 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return Session{}, 0, err
+	}
+
+	return ParseSessionResponse(respData)
+}
+
+// DestroySession 销毁会话（写操作，通过 Raft 复制）
+func (r *RaftRegistry) DestroySession(ctx context.Context, id string) (uint64, error) {
+	cmdData, err := BuildSessionDestroyCommand(id)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	_, idx, err := ParseSessionResponse(respData)
+	return idx, err
+}
+
+// RenewSession 续约会话（写操作，通过 Raft 复制）
+func (r *RaftRegistry) RenewSession(ctx context.Context, id string) (Session, uint64, error) {
+	cmdData, err := BuildSessionRenewCommand(id)
+	if err != nil {
+		return Session{}, 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return Session{}, 0, err
+	}
+
+	return ParseSessionResponse(respData)
+}
+
+// UpsertPreparedQuery 创建或更新预设查询（写操作，通过 Raft 复制）
+func (r *RaftRegistry) UpsertPreparedQuery(ctx context.Context, q PreparedQuery) (uint64, error) {
+	cmdData, err := BuildQuerySetCommand(q)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// DeletePreparedQuery 删除预设查询（写操作，通过 Raft 复制）
+func (r *RaftRegistry) DeletePreparedQuery(ctx context.Context, name string) (uint64, error) {
+	cmdData, err := BuildQueryDeleteCommand(name)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// UpsertServiceDefaults 创建或更新服务级配置项（写操作，通过 Raft 复制）
+func (r *RaftRegistry) UpsertServiceDefaults(ctx context.Context, d ServiceDefaults) (uint64, error) {
+	cmdData, err := BuildConfigSetCommand(d)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// DeleteServiceDefaults 删除服务级配置项（写操作，通过 Raft 复制）
+func (r *RaftRegistry) DeleteServiceDefaults(ctx context.Context, namespace, name string) (uint64, error) {
+	cmdData, err := BuildConfigDeleteCommand(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// UpsertIntention 创建或更新调用规则（写操作，通过 Raft 复制）；ID 在提交前生成，保证各节点一致
+func (r *RaftRegistry) UpsertIntention(ctx context.Context, ix Intention) (string, uint64, error) {
+	if ix.ID == "" {
+		ix.ID = newUUID()
+	}
+	cmdData, err := BuildIntentionSetCommand(ix)
+	if err != nil {
+		return "", 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return "", 0, err
+	}
+
+	idx, err := ParseIndexResponse(respData)
+	if err != nil {
+		return "", idx, err
+	}
+	return ix.ID, idx, nil
+}
+
+// DeleteIntention 删除调用规则（写操作，通过 Raft 复制）
+func (r *RaftRegistry) DeleteIntention(ctx context.Context, id string) (uint64, error) {
+	cmdData, err := BuildIntentionDeleteCommand(id)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// SetIntentionConfig 更新调用规则全局配置（写操作，通过 Raft 复制）
+func (r *RaftRegistry) SetIntentionConfig(ctx context.Context, c IntentionConfig) (uint64, error) {
+	cmdData, err := BuildIntentionConfigCommand(c)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// UpsertServiceSplitter 创建或更新流量拆分配置（写操作，通过 Raft 复制）
+func (r *RaftRegistry) UpsertServiceSplitter(ctx context.Context, s ServiceSplitter) (uint64, error) {
+	cmdData, err := BuildSplitterSetCommand(s)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// DeleteServiceSplitter 删除流量拆分配置（写操作，通过 Raft 复制）
+func (r *RaftRegistry) DeleteServiceSplitter(ctx context.Context, namespace, name string) (uint64, error) {
+	cmdData, err := BuildSplitterDeleteCommand(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseIndexResponse(respData)
+}
+
+// SetServerHTTPAddr 发布 Raft 节点的 HTTP 地址（写操作，通过 Raft 复制）
+func (r *RaftRegistry) SetServerHTTPAddr(id, httpAddr string) error {
+	cmdData, err := BuildSetServerCommand(id, httpAddr)
+	if err != nil {
+		return err
+	}
+
+	return r.applyIndexCommand(cmdData)
+}
+
 // ============================================================================
 // 读操作 - 直接从内存读取
 // ============================================================================
@@ -108,15 +444,254 @@ func (r *RaftRegistry) ListServices(ctx context.Context, namespace string) ([]st
 	return r.mem.ListServices(ctx, namespace)
 }
 
+// ListNodes 列出所有节点（读操作，直接从内存读取）
+func (r *RaftRegistry) ListNodes(ctx context.Context) ([]NodeView, uint64, error) {
+	return r.mem.ListNodes(ctx)
+}
+
+// GetNode 查询节点及其实例（读操作，直接从内存读取）
+func (r *RaftRegistry) GetNode(ctx context.Context, name string) (NodeView, uint64, error) {
+	return r.mem.GetNode(ctx, name)
+}
+
+// GetCheck 查询检查的运行时状态（读操作，直接从内存读取）
+func (r *RaftRegistry) GetCheck(ctx context.Context, checkID string) (CheckView, uint64, error) {
+	return r.mem.GetCheck(ctx, checkID)
+}
+
+// ServiceChecks 列出服务实例的检查（读操作，直接从内存读取）
+func (r *RaftRegistry) ServiceChecks(ctx context.Context, namespace, service string) ([]CheckView, uint64, error) {
+	return r.mem.ServiceChecks(ctx, namespace, service)
+}
+
+// ChecksInState 按状态列出检查（读操作，直接从内存读取）
+func (r *RaftRegistry) ChecksInState(ctx context.Context, namespace, state string) ([]CheckView, uint64, error) {
+	return r.mem.ChecksInState(ctx, namespace, state)
+}
+
+// ListInstanceHealth 查询附带检查的实例（读操作，直接从内存读取）
+func (r *RaftRegistry) ListInstanceHealth(ctx context.Context, namespace, service string, opts ListOptions) ([]InstanceHealth, uint64, error) {
+	return r.mem.ListInstanceHealth(ctx, namespace, service, opts)
+}
+
+// CheckHistory 查询检查状态切换历史（读操作，直接从内存读取）
+func (r *RaftRegistry) CheckHistory(ctx context.Context, checkID string) (CheckHistory, uint64, error) {
+	return r.mem.CheckHistory(ctx, checkID)
+}
+
+// ListFlappingChecks 列出处于抖动的检查（读操作，直接从内存读取）
+func (r *RaftRegistry) ListFlappingChecks(ctx context.Context) ([]CheckHistory, uint64, error) {
+	return r.mem.ListFlappingChecks(ctx)
+}
+
+// ListNamespaces 列出命名空间（读操作，直接从内存读取）
+func (r *RaftRegistry) ListNamespaces(ctx context.Context) ([]NamespaceView, uint64, error) {
+	return r.mem.ListNamespaces(ctx)
+}
+
+// GetNamespace 查询命名空间（读操作，直接从内存读取）
+func (r *RaftRegistry) GetNamespace(ctx context.Context, name string) (NamespaceView, uint64, error) {
+	return r.mem.GetNamespace(ctx, name)
+}
+
+// GetNamespaceConfig 查询命名空间全局配置（读操作，直接从内存读取）
+func (r *RaftRegistry) GetNamespaceConfig(ctx context.Context) (NamespaceConfig, uint64, error) {
+	return r.mem.GetNamespaceConfig(ctx)
+}
+
 // WatchService 监听服务变更（读操作，直接从内存监听）
 func (r *RaftRegistry) WatchService(ctx context.Context, namespace, service string, lastIndex uint64) (uint64, <-chan struct{}) {
 	return r.mem.WatchService(ctx, namespace, service, lastIndex)
 }
 
+// WatchNamespace 监听命名空间目录变化（读操作，直接从内存监听）
+func (r *RaftRegistry) WatchNamespace(ctx context.Context, namespace string, lastIndex uint64) (uint64, <-chan struct{}) {
+	return r.mem.WatchNamespace(ctx, namespace, lastIndex)
+}
+
+// WatchHealth 监听任意检查变化（读操作，直接从内存监听）
+func (r *RaftRegistry) WatchHealth(ctx context.Context, lastIndex uint64) (uint64, <-chan struct{}) {
+	return r.mem.WatchHealth(ctx, lastIndex)
+}
+
+// KVGet 查询单个键（读操作，直接从内存读取）
+func (r *RaftRegistry) KVGet(ctx context.Context, key string) (*KVEntry, uint64, error) {
+	return r.mem.KVGet(ctx, key)
+}
+
+// KVList 按前缀列出键值（读操作，直接从内存读取）
+func (r *RaftRegistry) KVList(ctx context.Context, prefix string) ([]KVEntry, uint64, error) {
+	return r.mem.KVList(ctx, prefix)
+}
+
+// KVWatch 监听键或前缀变化（读操作，直接从内存监听）
+func (r *RaftRegistry) KVWatch(ctx context.Context, key string, recurse bool, lastIndex uint64) (uint64, <-chan struct{}) {
+	return r.mem.KVWatch(ctx, key, recurse, lastIndex)
+}
+
+// GetSession 查询会话（读操作，直接从内存读取）
+func (r *RaftRegistry) GetSession(ctx context.Context, id string) (Session, uint64, error) {
+	return r.mem.GetSession(ctx, id)
+}
+
+// ListSessions 列出会话（读操作，直接从内存读取）
+func (r *RaftRegistry) ListSessions(ctx context.Context) ([]Session, uint64, error) {
+	return r.mem.ListSessions(ctx)
+}
+
+// GetPreparedQuery 查询预设查询定义（读操作，直接从内存读取）
+func (r *RaftRegistry) GetPreparedQuery(ctx context.Context, name string) (PreparedQuery, uint64, error) {
+	return r.mem.GetPreparedQuery(ctx, name)
+}
+
+// ListPreparedQueries 列出预设查询（读操作，直接从内存读取）
+func (r *RaftRegistry) ListPreparedQueries(ctx context.Context) ([]PreparedQuery, uint64, error) {
+	return r.mem.ListPreparedQueries(ctx)
+}
+
+// ExecutePreparedQuery 执行预设查询（读操作，直接从内存读取）
+func (r *RaftRegistry) ExecutePreparedQuery(ctx context.Context, name string) (PreparedQueryResult, uint64, error) {
+	return r.mem.ExecutePreparedQuery(ctx, name)
+}
+
+// GetServiceDefaults 查询服务级配置项（读操作，直接从内存读取）
+func (r *RaftRegistry) GetServiceDefaults(ctx context.Context, namespace, name string) (ServiceDefaults, uint64, error) {
+	return r.mem.GetServiceDefaults(ctx, namespace, name)
+}
+
+// ListServiceDefaults 列出服务级配置项（读操作，直接从内存读取）
+func (r *RaftRegistry) ListServiceDefaults(ctx context.Context, namespace string) ([]ServiceDefaults, uint64, error) {
+	return r.mem.ListServiceDefaults(ctx, namespace)
+}
+
+// GetIntention 查询调用规则（读操作，直接从内存读取）
+func (r *RaftRegistry) GetIntention(ctx context.Context, id string) (Intention, uint64, error) {
+	return r.mem.GetIntention(ctx, id)
+}
+
+// ListIntentions 列出调用规则（读操作，直接从内存读取）
+func (r *RaftRegistry) ListIntentions(ctx context.Context) ([]Intention, uint64, error) {
+	return r.mem.ListIntentions(ctx)
+}
+
+// GetIntentionConfig 查询调用规则全局配置（读操作，直接从内存读取）
+func (r *RaftRegistry) GetIntentionConfig(ctx context.Context) (IntentionConfig, uint64, error) {
+	return r.mem.GetIntentionConfig(ctx)
+}
+
+// CheckIntention 评估服务间调用是否被允许（读操作，直接从内存读取）
+func (r *RaftRegistry) CheckIntention(ctx context.Context, srcNS, src, dstNS, dst string) (IntentionCheck, uint64, error) {
+	return r.mem.CheckIntention(ctx, srcNS, src, dstNS, dst)
+}
+
+// GetServiceSplitter 查询流量拆分配置（读操作，直接从内存读取）
+func (r *RaftRegistry) GetServiceSplitter(ctx context.Context, namespace, name string) (ServiceSplitter, uint64, error) {
+	return r.mem.GetServiceSplitter(ctx, namespace, name)
+}
+
+// ListServiceSplitters 列出流量拆分配置（读操作，直接从内存读取）
+func (r *RaftRegistry) ListServiceSplitters(ctx context.Context, namespace string) ([]ServiceSplitter, uint64, error) {
+	return r.mem.ListServiceSplitters(ctx, namespace)
+}
+
+// ResolveService 按流量拆分配置解析服务实例（读操作，直接从内存读取）
+func (r *RaftRegistry) ResolveService(ctx context.Context, namespace, service string, opts ListOptions) (ServiceResolution, uint64, error) {
+	return r.mem.ResolveService(ctx, namespace, service, opts)
+}
+
+// ServerHTTPAddr 返回 Raft 节点的 HTTP 地址（未知时为空）
+func (r *RaftRegistry) ServerHTTPAddr(id string) string {
+	return r.mem.serverAddr(id)
+}
+
 // ============================================================================
 // 内部辅助方法
 // ============================================================================
 
+// expirer 每秒扫描一次超时的 TTL 检查、持续 critical 的实例、过期的会话与可回收的键值墓碑，并通过 Raft 提交相应命令。
+func (r *RaftRegistry) expirer(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.expireOnce(); err != nil {
+				log.Printf("TTL 过期/自动注销提交失败: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (r *RaftRegistry) expireOnce() error {
+	now := time.Now()
+	r.mem.mu.RLock()
+	ids := r.mem.expiredChecksLocked(now)
+	r.mem.mu.RUnlock()
+	if len(ids) > 0 {
+		cmdData, err := BuildExpireChecksCommand(ids)
+		if err != nil {
+			return err
+		}
+		if err := r.applyIndexCommand(cmdData); err != nil {
+			return err
+		}
+	}
+
+	// 持续 critical 超过 DeregisterCriticalAfter 的实例自动注销
+	r.mem.mu.RLock()
+	keys := r.mem.reapableInstancesLocked(time.Now())
+	r.mem.mu.RUnlock()
+	if len(keys) > 0 {
+		cmdData, err := BuildReapCriticalCommand(keys)
+		if err != nil {
+			return err
+		}
+		if err := r.applyIndexCommand(cmdData); err != nil {
+			return err
+		}
+	}
+
+	// 超过 TTL 未续约的会话销毁并释放其持有的锁
+	r.mem.mu.RLock()
+	sids := r.mem.expiredSessionsLocked(time.Now())
+	r.mem.mu.RUnlock()
+	if len(sids) > 0 {
+		cmdData, err := BuildExpireSessionsCommand(sids)
+		if err != nil {
+			return err
+		}
+		if err := r.applyIndexCommand(cmdData); err != nil {
+			return err
+		}
+	}
+
+	// 删除超过 KVTombstoneTTL 的键值墓碑按索引水位回收
+	r.mem.mu.RLock()
+	w := r.mem.tombstoneWatermarkLocked(time.Now())
+	r.mem.mu.RUnlock()
+	if w == 0 {
+		return nil
+	}
+	cmdData, err := BuildReapTombstonesCommand(w)
+	if err != nil {
+		return err
+	}
+	return r.applyIndexCommand(cmdData)
+}
+
+// applyIndexCommand 提交返回通用索引响应的命令，仅关心错误
+func (r *RaftRegistry) applyIndexCommand(cmdData []byte) error {
+	respData, err := r.applyCommand(cmdData)
+	if err != nil {
+		return err
+	}
+
+	_, err = ParseIndexResponse(respData)
+	return err
+}
+
 // applyCommand 提交命令到 Raft 并等待响应
 func (r *RaftRegistry) applyCommand(cmdData []byte) ([]byte, error) {
 	future := r.raft.Apply(cmdData, 5*time.Second)
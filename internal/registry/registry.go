@@ -12,12 +12,90 @@ type Registry interface {
 
 	// TTL 与外部检查
 	RenewTTL(ctx context.Context, checkID string) (idx uint64, err error)
-	ReportCheck(ctx context.Context, checkID string, status CheckStatus, output string) (idx uint64, err error)
+	ReportCheck(ctx context.Context, checkID string, status CheckStatus, output, note string) (idx uint64, err error)
+
+	// 维护模式：id 为空时作用于 namespace/service 下的全部实例
+	SetMaintenance(ctx context.Context, namespace, service, id string, enable bool, reason string) (idx uint64, err error)
+
+	// 节点：节点级检查的最坏状态会叠加到该节点上所有实例
+	RegisterNode(ctx context.Context, node Node, specs []CheckSpec) (idx uint64, checkIDs []string, err error)
+	DeregisterNode(ctx context.Context, name string) (idx uint64, err error)
+
+	// 命名空间：显式创建/删除，可设置配额
+	UpsertNamespace(ctx context.Context, ns Namespace) (idx uint64, err error)
+	DeleteNamespace(ctx context.Context, name string) (idx uint64, err error)
+	ListNamespaces(ctx context.Context) (namespaces []NamespaceView, idx uint64, err error)
+	GetNamespace(ctx context.Context, name string) (ns NamespaceView, idx uint64, err error)
+	// 严格模式为复制状态，保证各节点对同一注册命令的准入结果一致
+	SetNamespaceConfig(ctx context.Context, c NamespaceConfig) (idx uint64, err error)
+	GetNamespaceConfig(ctx context.Context) (config NamespaceConfig, idx uint64, err error)
+
+	// 键值存储：写经 Raft；ok 为 false 表示 CAS 条件不满足
+	KVApply(ctx context.Context, op KVOp) (ok bool, idx uint64, err error)
+	KVGet(ctx context.Context, key string) (entry *KVEntry, idx uint64, err error)
+	KVList(ctx context.Context, prefix string) (entries []KVEntry, idx uint64, err error)
+	KVWatch(ctx context.Context, key string, recurse bool, lastIndex uint64) (idx uint64, notify <-chan struct{})
+
+	// 会话：用于 KV 加锁；失效（销毁、TTL 过期、关联检查 critical）时释放或删除其持有的键
+	CreateSession(ctx context.Context, s Session) (session Session, idx uint64, err error)
+	DestroySession(ctx context.Context, id string) (idx uint64, err error)
+	RenewSession(ctx context.Context, id string) (session Session, idx uint64, err error)
+	GetSession(ctx context.Context, id string) (session Session, idx uint64, err error)
+	ListSessions(ctx context.Context) (sessions []Session, idx uint64, err error)
+
+	// 预设查询：保存服务查询条件，执行时无 passing 实例则按顺序回退到其他命名空间
+	UpsertPreparedQuery(ctx context.Context, q PreparedQuery) (idx uint64, err error)
+	DeletePreparedQuery(ctx context.Context, name string) (idx uint64, err error)
+	GetPreparedQuery(ctx context.Context, name string) (query PreparedQuery, idx uint64, err error)
+	ListPreparedQueries(ctx context.Context) (queries []PreparedQuery, idx uint64, err error)
+	ExecutePreparedQuery(ctx context.Context, name string) (result PreparedQueryResult, idx uint64, err error)
+
+	// 服务级配置项：注册实例时合并到实例（实例自身配置优先）
+	UpsertServiceDefaults(ctx context.Context, d ServiceDefaults) (idx uint64, err error)
+	DeleteServiceDefaults(ctx context.Context, namespace, name string) (idx uint64, err error)
+	GetServiceDefaults(ctx context.Context, namespace, name string) (defaults ServiceDefaults, idx uint64, err error)
+	ListServiceDefaults(ctx context.Context, namespace string) (defaults []ServiceDefaults, idx uint64, err error)
+
+	// 服务间调用规则：按精确度取最高优先级的匹配规则，无匹配时使用默认策略
+	UpsertIntention(ctx context.Context, ix Intention) (id string, idx uint64, err error)
+	DeleteIntention(ctx context.Context, id string) (idx uint64, err error)
+	GetIntention(ctx context.Context, id string) (ix Intention, idx uint64, err error)
+	ListIntentions(ctx context.Context) (intentions []Intention, idx uint64, err error)
+	CheckIntention(ctx context.Context, srcNS, src, dstNS, dst string) (result IntentionCheck, idx uint64, err error)
+	// 默认策略为复制状态，保证各节点评估结果一致
+	SetIntentionConfig(ctx context.Context, c IntentionConfig) (idx uint64, err error)
+	GetIntentionConfig(ctx context.Context) (config IntentionConfig, idx uint64, err error)
+
+	// 子集定义与流量拆分：解析时按子集分组健康查询结果并附带流量百分比
+	UpsertServiceSplitter(ctx context.Context, s ServiceSplitter) (idx uint64, err error)
+	DeleteServiceSplitter(ctx context.Context, namespace, name string) (idx uint64, err error)
+	GetServiceSplitter(ctx context.Context, namespace, name string) (splitter ServiceSplitter, idx uint64, err error)
+	ListServiceSplitters(ctx context.Context, namespace string) (splitters []ServiceSplitter, idx uint64, err error)
+	ResolveService(ctx context.Context, namespace, service string, opts ListOptions) (res ServiceResolution, idx uint64, err error)
 
 	// 读接口
 	ListHealthyInstances(ctx context.Context, namespace, service string, opts ListOptions) (views []InstanceView, idx uint64, err error)
+	// ListServices 返回的 idx 为命名空间级索引，仅在该命名空间的实例增删改时推进。
 	ListServices(ctx context.Context, namespace string) (names []string, idx uint64, err error)
+	ListNodes(ctx context.Context) (nodes []NodeView, idx uint64, err error)
+	GetNode(ctx context.Context, name string) (node NodeView, idx uint64, err error)
+
+	// ListInstanceHealth 同 ListHealthyInstances，每个实例附带聚合状态与检查（含所在节点的检查）。
+	ListInstanceHealth(ctx context.Context, namespace, service string, opts ListOptions) (instances []InstanceHealth, idx uint64, err error)
+
+	// 检查查询：ServiceChecks 的 idx 为服务索引，ChecksInState 的 idx 为健康索引（配合 WatchHealth）
+	GetCheck(ctx context.Context, checkID string) (check CheckView, idx uint64, err error)
+	ServiceChecks(ctx context.Context, namespace, service string) (checks []CheckView, idx uint64, err error)
+	ChecksInState(ctx context.Context, namespace, state string) (checks []CheckView, idx uint64, err error)
+
+	// 检查状态切换历史与抖动检测
+	CheckHistory(ctx context.Context, checkID string) (history CheckHistory, idx uint64, err error)
+	ListFlappingChecks(ctx context.Context) (checks []CheckHistory, idx uint64, err error)
 
 	// 监听指定服务的变更；若 lastIndex 落后，会立刻触发一次通知。
 	WatchService(ctx context.Context, namespace, service string, lastIndex uint64) (idx uint64, notify <-chan struct{})
+	// 监听命名空间的目录变化（实例增删改），配合 ListServices 的索引使用。
+	WatchNamespace(ctx context.Context, namespace string, lastIndex uint64) (idx uint64, notify <-chan struct{})
+	// 监听任意服务或节点检查的变化，配合 ChecksInState 的索引使用。
+	WatchHealth(ctx context.Context, lastIndex uint64) (idx uint64, notify <-chan struct{})
 }
@@ -12,6 +12,9 @@ const (
 	CheckHTTP CheckType = "http"
 	CheckTCP  CheckType = "tcp"
 	CheckCmd  CheckType = "cmd"
+
+	// CheckMaint 为维护模式注入的合成检查，状态恒为 critical，不能通过检查接口更新。
+	CheckMaint CheckType = "maintenance"
 )
 
 // CheckStatus 表示健康检查的当前状态。
@@ -24,12 +27,35 @@ const (
 	StatusCritical
 )
 
+// String 返回状态的可读名称（passing/warning/critical/unknown）。
+func (s CheckStatus) String() string {
+	switch s {
+	case StatusPassing:
+		return "passing"
+	case StatusWarning:
+		return "warning"
+	case StatusCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
 // Weights 定义负载均衡时的权重（通过或警告状态）。
 type Weights struct {
 	Passing int `json:"Passing"`
 	Warning int `json:"Warning"`
 }
 
+// 权重为 0 时使用的默认值。
+const (
+	DefaultPassingWeight = 1
+	DefaultWarningWeight = 1
+)
+
+// OrderWeighted 表示按实例权重做加权随机排序。
+const OrderWeighted = "weighted"
+
 // CheckSpec 定义一个健康检查的配置。
 type CheckSpec struct {
 	Type     CheckType     `json:"Type"`
@@ -40,6 +66,14 @@ type CheckSpec struct {
 	IntRaw   string        `json:"Interval"`
 	Timeout  time.Duration `json:"-"`
 	TmRaw    string        `json:"Timeout"`
+
+	// DeregisterCriticalAfter: 检查持续 critical 超过该时长后，由 Leader 自动注销实例（0 表示不启用）
+	DeregisterCriticalAfter time.Duration `json:"-"`
+	DCARaw                  string        `json:"DeregisterCriticalAfter"`
+
+	// 状态切换阈值：连续 N 次成功才转为 passing、连续 N 次失败才转为 critical（0 或 1 表示立即切换）
+	SuccessBeforePassing   int `json:"SuccessBeforePassing,omitempty"`
+	FailuresBeforeCritical int `json:"FailuresBeforeCritical,omitempty"`
 }
 
 // Check 保存某一次健康检查的运行时状态。
@@ -48,8 +82,120 @@ type Check struct {
 	Spec       CheckSpec
 	Status     CheckStatus
 	Output     string
+	Note       string // 最近一次上报附带的说明（可选）
 	LastUpdate time.Time
 	LastPass   time.Time
+
+	// CriticalSince 记录进入 critical 的时间；非 critical 时为零值
+	CriticalSince time.Time
+
+	// 连续成功/失败次数，用于 SuccessBeforePassing/FailuresBeforeCritical
+	SuccessCount int
+	FailureCount int
+
+	// History 为最近的状态切换记录（按时间升序，最多 CheckHistorySize 条）
+	History []CheckTransition
+}
+
+// CheckHistorySize 为每个检查保留的状态切换记录条数。
+const CheckHistorySize = 32
+
+// CheckTransition 记录检查的一次状态切换。
+type CheckTransition struct {
+	From   string    `json:"From"`
+	To     string    `json:"To"`
+	At     time.Time `json:"At"`
+	Output string    `json:"Output,omitempty"`
+}
+
+// CheckHistory 为检查状态切换历史的对外视图。
+type CheckHistory struct {
+	CheckID     string            `json:"CheckID"`
+	Namespace   string            `json:"Namespace,omitempty"`
+	Service     string            `json:"Service,omitempty"`
+	InstanceID  string            `json:"InstanceID,omitempty"`
+	Node        string            `json:"Node,omitempty"`
+	Status      string            `json:"Status"`
+	Flapping    bool              `json:"Flapping"`
+	Transitions []CheckTransition `json:"Transitions"`
+}
+
+// CheckView 为检查运行时状态的对外视图，附带所属实例或节点。
+type CheckView struct {
+	CheckID    string    `json:"CheckID"`
+	Namespace  string    `json:"Namespace,omitempty"`
+	Service    string    `json:"Service,omitempty"`
+	InstanceID string    `json:"InstanceID,omitempty"`
+	Node       string    `json:"Node,omitempty"`
+	Type       CheckType `json:"Type"`
+	Status     string    `json:"Status"`
+	Output     string    `json:"Output"`
+	Note       string    `json:"Note,omitempty"`
+	LastUpdate time.Time `json:"LastUpdate"`
+}
+
+// InstanceHealth 为附带检查的实例视图：Status 为聚合状态，Checks 含实例检查及其所在节点的检查。
+type InstanceHealth struct {
+	InstanceView
+	Status string      `json:"Status,omitempty"`
+	Checks []CheckView `json:"Checks,omitempty"`
+}
+
+// CheckStateAny 用于按状态列出检查时匹配任意状态。
+const CheckStateAny = "any"
+
+// setStatus 更新检查状态与时间戳，并维护 CriticalSince。
+func (c *Check) setStatus(st CheckStatus, now time.Time) {
+	if st == StatusCritical {
+		if c.Status != StatusCritical || c.CriticalSince.IsZero() {
+			c.CriticalSince = now
+		}
+	} else {
+		c.CriticalSince = time.Time{}
+	}
+	if st != c.Status {
+		c.History = append(c.History, CheckTransition{From: c.Status.String(), To: st.String(), At: now})
+		if n := len(c.History) - CheckHistorySize; n > 0 {
+			c.History = append([]CheckTransition(nil), c.History[n:]...)
+		}
+	}
+	c.Status = st
+	c.LastUpdate = now
+}
+
+// forceStatus 不经阈值直接切换状态（TTL 过期、维护模式等），并清零连续成功/失败计数，
+// 使之后的结果重新按 SuccessBeforePassing/FailuresBeforeCritical 累计。
+func (c *Check) forceStatus(st CheckStatus, now time.Time) {
+	c.SuccessCount, c.FailureCount = 0, 0
+	c.setStatus(st, now)
+}
+
+// applyResult 记录一次检查结果，并按阈值决定是否切换状态：
+// passing/warning 计为成功，连续成功达到 SuccessBeforePassing 后才生效；
+// critical 计为失败，连续失败达到 FailuresBeforeCritical 后才生效；
+// 当前已处于同类状态时立即生效。返回状态是否发生变化。
+func (c *Check) applyResult(st CheckStatus, now time.Time) bool {
+	prev := c.Status
+	switch st {
+	case StatusPassing, StatusWarning:
+		c.FailureCount = 0
+		c.SuccessCount++
+		if prev != StatusPassing && prev != StatusWarning && c.SuccessCount < c.Spec.SuccessBeforePassing {
+			c.LastUpdate = now
+			return false
+		}
+	case StatusCritical:
+		c.SuccessCount = 0
+		c.FailureCount++
+		if prev != StatusCritical && c.FailureCount < c.Spec.FailuresBeforeCritical {
+			c.LastUpdate = now
+			return false
+		}
+	default:
+		c.SuccessCount, c.FailureCount = 0, 0
+	}
+	c.setStatus(st, now)
+	return c.Status != prev
 }
 
 // ServiceInstance 描述某个服务的一个实例。
@@ -59,6 +205,8 @@ type ServiceInstance struct {
 	ID        string            `json:"ID"`
 	Address   string            `json:"Address"`
 	Port      int               `json:"Port"`
+	Node      string            `json:"Node,omitempty"` // 所属节点名（可选）；节点检查 critical 时实例视为不健康
+	Zone      string            `json:"Zone,omitempty"` // 所在可用区；为空时取 Meta["zone"]
 	Tags      []string          `json:"Tags"`
 	Meta      map[string]string `json:"Meta"`
 	Weights   Weights           `json:"Weights"`
@@ -72,7 +220,10 @@ type ServiceInstance struct {
 type ListOptions struct {
 	PassingOnly bool
 	Tag         string
-	Zone        string
+	Zone        string  // 仅返回该 zone 的实例
+	Near        string  // 就近 zone：本地有 passing 实例时只返回本地，否则回退其他 zone（本地排前）
+	Filter      *Filter // 过滤表达式（见 filter.go），nil 表示不过滤
+	Order       string  // 排序方式：空为按 ID 排序；OrderWeighted 为加权随机排序
 }
 
 // InstanceView 是返回给客户端的精简实例视图。
@@ -82,7 +233,250 @@ type InstanceView struct {
 	ID        string            `json:"ID"`
 	Address   string            `json:"Address"`
 	Port      int               `json:"Port"`
+	Node      string            `json:"Node,omitempty"`
+	Zone      string            `json:"Zone"`
 	Tags      []string          `json:"Tags"`
 	Meta      map[string]string `json:"Meta"`
 	Weights   Weights           `json:"Weights"`
+
+	status CheckStatus // 聚合状态，仅用于内部排序
+}
+
+// DefaultNamespace 为未指定命名空间时使用的命名空间，始终存在且不可删除。
+const DefaultNamespace = "default"
+
+// Namespace 描述一个显式创建的命名空间。
+type Namespace struct {
+	Name        string         `json:"Name"`
+	Description string         `json:"Description,omitempty"`
+	Quota       NamespaceQuota `json:"Quota"`
+	CreateIndex uint64         `json:"CreateIndex"`
+	ModifyIndex uint64         `json:"ModifyIndex"`
+}
+
+// NamespaceQuota 为命名空间的配额；0 表示不限制。
+type NamespaceQuota struct {
+	MaxInstances int `json:"MaxInstances"`
+	MaxServices  int `json:"MaxServices"`
+}
+
+// NamespaceConfig 为命名空间的全局配置，经 Raft 复制，保证各节点对同一注册命令的准入结果一致。
+type NamespaceConfig struct {
+	Strict      bool   `json:"Strict"` // 拒绝注册到未显式创建的命名空间（default 除外）
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// NamespaceView 为命名空间的对外视图，附带当前用量。
+// Implicit 表示该命名空间未显式创建（仅因注册实例而存在）。
+type NamespaceView struct {
+	Namespace
+	Implicit  bool `json:"Implicit,omitempty"`
+	Instances int  `json:"Instances"`
+	Services  int  `json:"Services"`
+}
+
+// KVEntry 为键值存储中的一个条目。Value 在 JSON 中以 base64 编码。
+type KVEntry struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	Flags       uint64 `json:"Flags"`
+	LockIndex   uint64 `json:"LockIndex"`         // 成功加锁的次数
+	Session     string `json:"Session,omitempty"` // 当前持有锁的会话
+	CreateIndex uint64 `json:"CreateIndex"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// KVVerb 为键值写操作的类型。
+type KVVerb string
+
+const (
+	KVSet        KVVerb = "set"         // 无条件写入
+	KVCAS        KVVerb = "cas"         // Index 为 0 时仅在键不存在时写入，否则仅在 ModifyIndex 等于 Index 时写入
+	KVDelete     KVVerb = "delete"      // 删除单个键
+	KVDeleteCAS  KVVerb = "delete-cas"  // 仅在 ModifyIndex 等于 Index 时删除
+	KVDeleteTree KVVerb = "delete-tree" // 删除以 Key 为前缀的全部键
+	KVLock       KVVerb = "lock"        // 以 Session 加锁并写入；已被其他会话持有或处于 lock-delay 时失败
+	KVUnlock     KVVerb = "unlock"      // 由持有锁的 Session 释放并写入
+)
+
+// KVOp 描述一次键值写操作。
+type KVOp struct {
+	Verb    KVVerb `json:"Verb"`
+	Key     string `json:"Key"`
+	Value   []byte `json:"Value,omitempty"`
+	Flags   uint64 `json:"Flags,omitempty"`
+	Index   uint64 `json:"Index,omitempty"`   // CAS 使用的 ModifyIndex
+	Session string `json:"Session,omitempty"` // lock/unlock 使用的会话 ID
+}
+
+// MaxKVValueSize 为单个值的大小上限，避免大对象进入 Raft 日志。
+const MaxKVValueSize = 512 * 1024
+
+// SessionBehavior 决定会话失效时其持有的锁如何处理。
+type SessionBehavior string
+
+const (
+	SessionRelease SessionBehavior = "release" // 释放锁，保留键值（默认）
+	SessionDelete  SessionBehavior = "delete"  // 删除持有的键
+)
+
+// 会话 TTL 的取值范围与 lock-delay 的默认值/上限。
+const (
+	SessionTTLMin       = 10 * time.Second
+	SessionTTLMax       = 24 * time.Hour
+	DefaultLockDelay    = 15 * time.Second
+	SessionLockDelayMax = 60 * time.Second
+)
+
+// Session 为分布式锁的持有者。会话在以下情况下失效：被显式销毁；
+// 设置了 TTL 且超过 TTL 未续约；关联的任一检查变为 critical 或被删除。
+type Session struct {
+	ID       string          `json:"ID"`
+	Name     string          `json:"Name,omitempty"`
+	Checks   []string        `json:"Checks"` // 关联的检查 ID（实例检查或节点检查）
+	Behavior SessionBehavior `json:"Behavior"`
+
+	TTL          time.Duration `json:"-"`
+	TTLRaw       string        `json:"TTL,omitempty"`
+	LockDelay    time.Duration `json:"-"` // 会话失效后，其释放的键在该时长内不可再被加锁
+	LockDelayRaw string        `json:"LockDelay"`
+
+	LastRenew   time.Time `json:"LastRenew"`
+	CreateIndex uint64    `json:"CreateIndex"`
+	ModifyIndex uint64    `json:"ModifyIndex"`
+}
+
+// PreparedQuery 为按名称保存的服务查询。执行时先查询 Namespace，
+// 没有 passing 实例时依次回退到 Failover 中的命名空间。
+type PreparedQuery struct {
+	Name        string   `json:"Name"`
+	Namespace   string   `json:"Namespace"`
+	Service     string   `json:"Service"`
+	Tag         string   `json:"Tag,omitempty"`
+	Filter      string   `json:"Filter,omitempty"` // 过滤表达式（见 filter.go），保存时校验
+	OnlyPassing bool     `json:"OnlyPassing"`
+	Failover    []string `json:"Failover,omitempty"` // 回退命名空间，按顺序尝试
+	CreateIndex uint64   `json:"CreateIndex"`
+	ModifyIndex uint64   `json:"ModifyIndex"`
+}
+
+// PreparedQueryResult 为执行预设查询的结果；Namespace 为实际应答的命名空间，
+// Failovers 为应答前跳过的命名空间数（0 表示主命名空间直接应答）。
+type PreparedQueryResult struct {
+	Query     string         `json:"Query"`
+	Service   string         `json:"Service"`
+	Namespace string         `json:"Namespace"`
+	Failovers int            `json:"Failovers"`
+	Instances []InstanceView `json:"Instances"`
+}
+
+// ServiceDefaults 为服务级配置项，保存同一服务所有实例共享的元数据与默认值。
+// 注册实例时合并：仅填充实例未设置的字段，实例自身的配置优先。
+type ServiceDefaults struct {
+	Namespace string            `json:"Namespace"`
+	Name      string            `json:"Name"`
+	Protocol  string            `json:"Protocol,omitempty"` // 合并为实例 Meta["protocol"]
+	Meta      map[string]string `json:"Meta,omitempty"`     // 合并为实例 Meta 中缺失的键
+	Weights   Weights           `json:"Weights"`            // 实例权重为 0 时使用
+
+	// TTL 检查未设置 TTL 时使用的默认值
+	TTL    time.Duration `json:"-"`
+	TTLRaw string        `json:"TTL,omitempty"`
+
+	CreateIndex uint64 `json:"CreateIndex"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// IntentionAction 为服务间调用规则的动作。
+type IntentionAction string
+
+const (
+	IntentionAllow IntentionAction = "allow"
+	IntentionDeny  IntentionAction = "deny"
+)
+
+// IntentionWildcard 匹配任意命名空间或服务名。
+const IntentionWildcard = "*"
+
+// Intention 描述源服务对目标服务的调用是否被允许。
+// 命名空间与服务名均可为 "*"；命名空间为 "*" 时服务名也必须为 "*"。
+// 同一源/目标组合只能有一条规则；评估时取匹配规则中 Precedence 最高者。
+type Intention struct {
+	ID              string          `json:"ID"`
+	SourceNS        string          `json:"SourceNS"`
+	SourceName      string          `json:"SourceName"`
+	DestinationNS   string          `json:"DestinationNS"`
+	DestinationName string          `json:"DestinationName"`
+	Action          IntentionAction `json:"Action"`
+	Description     string          `json:"Description,omitempty"`
+	Precedence      int             `json:"Precedence"` // 由精确程度计算，目标优先于源（1~9）
+	CreateIndex     uint64          `json:"CreateIndex"`
+	ModifyIndex     uint64          `json:"ModifyIndex"`
+}
+
+// IntentionConfig 为调用规则的全局配置，经 Raft 复制，保证各节点的评估结果一致。
+type IntentionConfig struct {
+	DefaultDeny bool   `json:"DefaultDeny"` // 没有匹配的规则时拒绝调用（默认允许）
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// IntentionCheck 为一次调用规则评估的结果；Intention 为 nil 表示没有匹配规则，使用默认策略。
+type IntentionCheck struct {
+	Allowed   bool       `json:"Allowed"`
+	Intention *Intention `json:"Intention"`
+}
+
+// Node 描述运行 Agent 的主机。
+type Node struct {
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Meta    map[string]string `json:"Meta"`
+}
+
+// NodeView 是返回给客户端的节点视图。
+type NodeView struct {
+	Name      string            `json:"Name"`
+	Address   string            `json:"Address"`
+	Meta      map[string]string `json:"Meta"`
+	Status    string            `json:"Status"`              // 节点检查的聚合状态
+	Instances []InstanceView    `json:"Instances,omitempty"` // 仅单节点查询时返回
+}
+
+// ServiceSubset 为服务实例的命名子集，按标签和/或过滤表达式选取实例（两者均为空时包含全部实例）。
+type ServiceSubset struct {
+	Name   string `json:"Name"`
+	Tag    string `json:"Tag,omitempty"`
+	Filter string `json:"Filter,omitempty"` // 过滤表达式（见 filter.go），保存时校验
+}
+
+// ServiceSplit 为流量拆分中的一项：Percent 为分给 Subset 的流量百分比。
+type ServiceSplit struct {
+	Subset  string `json:"Subset"`
+	Percent int    `json:"Percent"`
+}
+
+// ServiceSplitter 为服务的子集定义与流量拆分配置，供客户端按比例选择实例（如金丝雀发布）。
+// Splits 不能为空，各项 Percent 之和须为 100；未出现在 Splits 中的子集不分配流量。
+type ServiceSplitter struct {
+	Namespace   string          `json:"Namespace"`
+	Name        string          `json:"Name"`
+	Subsets     []ServiceSubset `json:"Subsets"`
+	Splits      []ServiceSplit  `json:"Splits"`
+	CreateIndex uint64          `json:"CreateIndex"`
+	ModifyIndex uint64          `json:"ModifyIndex"`
+}
+
+// ResolvedSubset 为解析结果中的一个子集及其实例。
+type ResolvedSubset struct {
+	Name      string         `json:"Name"`
+	Percent   int            `json:"Percent"`
+	Instances []InstanceView `json:"Instances"`
+}
+
+// ServiceResolution 为按流量拆分配置解析服务的结果。服务未配置拆分时，
+// Subsets 仅含一个 Name 为空、Percent 为 100 的子集，包含全部实例。
+type ServiceResolution struct {
+	Namespace string           `json:"Namespace"`
+	Service   string           `json:"Service"`
+	Subsets   []ResolvedSubset `json:"Subsets"`
 }
@@ -0,0 +1,139 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckApplyResultThresholds(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		spec    CheckSpec
+		initial CheckStatus
+		forced  bool // 先经 forceStatus 置为 critical（模拟 TTL 过期/维护）
+		results []CheckStatus
+		want    []CheckStatus // 每次上报后的状态
+	}{
+		{
+			name:    "no thresholds switch immediately",
+			initial: StatusCritical,
+			results: []CheckStatus{StatusPassing, StatusCritical, StatusWarning},
+			want:    []CheckStatus{StatusPassing, StatusCritical, StatusWarning},
+		},
+		{
+			name:    "success before passing",
+			spec:    CheckSpec{SuccessBeforePassing: 3},
+			initial: StatusCritical,
+			results: []CheckStatus{StatusPassing, StatusPassing, StatusPassing},
+			want:    []CheckStatus{StatusCritical, StatusCritical, StatusPassing},
+		},
+		{
+			name:    "failure resets success streak",
+			spec:    CheckSpec{SuccessBeforePassing: 2},
+			initial: StatusCritical,
+			results: []CheckStatus{StatusPassing, StatusCritical, StatusPassing, StatusPassing},
+			want:    []CheckStatus{StatusCritical, StatusCritical, StatusCritical, StatusPassing},
+		},
+		{
+			name:    "failures before critical",
+			spec:    CheckSpec{FailuresBeforeCritical: 2},
+			initial: StatusPassing,
+			results: []CheckStatus{StatusCritical, StatusPassing, StatusCritical, StatusCritical},
+			want:    []CheckStatus{StatusPassing, StatusPassing, StatusPassing, StatusCritical},
+		},
+		{
+			name:    "warning counts as success while healthy",
+			spec:    CheckSpec{SuccessBeforePassing: 2, FailuresBeforeCritical: 2},
+			initial: StatusPassing,
+			results: []CheckStatus{StatusWarning, StatusCritical, StatusPassing},
+			want:    []CheckStatus{StatusWarning, StatusWarning, StatusPassing},
+		},
+		{
+			name:    "forced critical restarts success streak",
+			spec:    CheckSpec{SuccessBeforePassing: 2},
+			initial: StatusPassing,
+			forced:  true,
+			results: []CheckStatus{StatusPassing, StatusPassing},
+			want:    []CheckStatus{StatusCritical, StatusPassing},
+		},
+		{
+			name:    "forced critical keeps failure threshold for next drop",
+			spec:    CheckSpec{FailuresBeforeCritical: 2},
+			initial: StatusPassing,
+			forced:  true,
+			results: []CheckStatus{StatusPassing, StatusCritical, StatusCritical},
+			want:    []CheckStatus{StatusPassing, StatusPassing, StatusCritical},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chk := Check{ID: "c", Spec: tt.spec, Status: tt.initial}
+			now := t0
+			if tt.forced {
+				// 先累计足够的成功次数，确认强制切换会将其清零
+				for i := 0; i < 5; i++ {
+					chk.applyResult(StatusPassing, now)
+				}
+				chk.forceStatus(StatusCritical, now)
+				if chk.SuccessCount != 0 || chk.FailureCount != 0 {
+					t.Fatalf("counters not reset: success=%d failure=%d", chk.SuccessCount, chk.FailureCount)
+				}
+			}
+			for i, st := range tt.results {
+				now = now.Add(time.Second)
+				chk.applyResult(st, now)
+				if chk.Status != tt.want[i] {
+					t.Fatalf("result %d (%s): status = %s, want %s", i, st, chk.Status, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRenewAfterTTLExpiryHonorsSuccessBeforePassing(t *testing.T) {
+	m := NewMemoryRegistryWithOptions(Options{})
+	t0 := time.Unix(1700000000, 0)
+	spec := CheckSpec{Type: CheckTTL, TTL: 10 * time.Second, SuccessBeforePassing: 2}
+	_, ids, err := m.registerAt(ServiceInstance{Service: "web", ID: "w1"}, []CheckSpec{spec}, t0)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	cid := ids[0]
+	status := func() string {
+		chk, _, err := m.GetCheck(context.Background(), cid)
+		if err != nil {
+			t.Fatalf("get check: %v", err)
+		}
+		return chk.Status
+	}
+
+	now := t0
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Second)
+		if _, err := m.renewTTLAt(cid, now); err != nil {
+			t.Fatalf("renew: %v", err)
+		}
+	}
+	if got := status(); got != StatusPassing.String() {
+		t.Fatalf("before expiry: status = %s, want passing", got)
+	}
+
+	now = now.Add(time.Minute)
+	m.expireChecksAt([]string{cid}, now)
+	if got := status(); got != StatusCritical.String() {
+		t.Fatalf("after expiry: status = %s, want critical", got)
+	}
+
+	now = now.Add(time.Second)
+	m.renewTTLAt(cid, now)
+	if got := status(); got != StatusCritical.String() {
+		t.Fatalf("first renew after expiry: status = %s, want critical", got)
+	}
+	now = now.Add(time.Second)
+	m.renewTTLAt(cid, now)
+	if got := status(); got != StatusPassing.String() {
+		t.Fatalf("second renew after expiry: status = %s, want passing", got)
+	}
+}
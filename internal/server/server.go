@@ -3,6 +3,8 @@ package server
 import (
     "context"
     "log"
+    "net"
+    "time"
     "sider/internal/api"
     "sider/internal/registry"
 
@@ -12,15 +14,23 @@ import (
 // Server 负责组装 Registry 与 HTTP API 并运行。
 type Server struct {
     HTTPAddr string
+    HTTPAdvertise string // 对其他节点公布的 HTTP 地址（host:port），留空则由 HTTPAddr/RaftBind 推导
     RaftID   string // 节点 ID
     RaftBind string // 监听地址（host:port）
     RaftDir  string // 数据目录
     Bootstrap bool  // 是否引导
+    FlapThreshold int           // 抖动判定：窗口内状态切换次数阈值（0 使用默认值）
+    FlapWindow    time.Duration // 抖动判定窗口（0 使用默认值）
+    CheckOutputMax int          // 检查输出的最大字节数（0 使用默认值）
 }
 
 func (s *Server) Run(ctx context.Context) error {
     // 1) 创建底层内存注册表（不自动启过期器，由 Leader 控制）。
-    mem := registry.NewMemoryRegistryWithOptions(registry.Options{AutoExpirer: false})
+    mem := registry.NewMemoryRegistryWithOptions(registry.Options{
+        AutoExpirer:   false,
+        FlapThreshold: s.FlapThreshold,
+        FlapWindow:    s.FlapWindow,
+    })
 
     // 2) 启动 Raft（hashicorp/raft）。
     fsm := registry.NewRaftFSMForServer(mem)
@@ -32,15 +42,33 @@ func (s *Server) Run(ctx context.Context) error {
     // 3) 将 Registry 写路径绑定到 Raft，读直读内存。
     rreg := registry.NewRaftRegistry(rn.Raft, mem)
 
-    // 4) 监听领导权变化，控制 TTL 过期器只在 Leader 上运行。
+    // 4) 监听领导权变化，控制 TTL 过期器只在 Leader 上运行（过期以 Raft 命令复制到所有节点）；
+    //    成为 Leader 时发布自身 HTTP 地址，供其他节点转发写请求。
+    advertise := s.advertiseAddr()
     go func(ch <-chan bool) {
         for isLeader := range ch {
-            if isLeader { mem.StartExpirer() } else { mem.Stop() }
+            if isLeader {
+                rreg.StartExpirer()
+                go func() {
+                    if err := rreg.SetServerHTTPAddr(s.RaftID, advertise); err != nil {
+                        log.Printf("发布 HTTP 地址失败: %v", err)
+                    }
+                }()
+            } else {
+                rreg.StopExpirer()
+            }
         }
     }(rn.Raft.LeaderCh())
 
-    // 5) 启动 HTTP 服务，并暴露 join 接口。
-    httpSrv := &api.HTTPServer{Reg: rreg, Addr: s.HTTPAddr, Joiner: raftJoiner{Raft: rn.Raft}, IsLeader: func() bool { return rn.Raft.State() == hraft.Leader }}
+    // 5) 启动 HTTP 服务，并暴露 join 接口；非 Leader 节点将写请求转发给 Leader。
+    httpSrv := &api.HTTPServer{
+        Reg:      rreg,
+        Addr:     s.HTTPAddr,
+        Joiner:   raftJoiner{Raft: rn.Raft, Reg: rreg},
+        Leader:   raftLeader{Raft: rn.Raft, Reg: rreg},
+        Consistency: raftLeader{Raft: rn.Raft, Reg: rreg},
+        CheckOutputMax: s.CheckOutputMax,
+    }
 
     defer rreg.Stop()
     if err := httpSrv.Start(ctx); err != nil {
@@ -50,18 +78,68 @@ func (s *Server) Run(ctx context.Context) error {
     return nil
 }
 
+// advertiseAddr 返回对外公布的 HTTP 地址；若监听地址未指定主机，则沿用 Raft 绑定地址的主机。
+func (s *Server) advertiseAddr() string {
+    if s.HTTPAdvertise != "" { return s.HTTPAdvertise }
+    host, port, err := net.SplitHostPort(s.HTTPAddr)
+    if err != nil { return s.HTTPAddr }
+    if host == "" || host == "0.0.0.0" || host == "::" {
+        host = "127.0.0.1"
+        if rh, _, err := net.SplitHostPort(s.RaftBind); err == nil && rh != "" && rh != "0.0.0.0" && rh != "::" {
+            host = rh
+        }
+    }
+    return net.JoinHostPort(host, port)
+}
+
 // raftJoiner 通过 Raft API 接受新节点加入（只允许在 Leader 上调用）。
-type raftJoiner struct { Raft *hraft.Raft }
+type raftJoiner struct {
+    Raft *hraft.Raft
+    Reg  *registry.RaftRegistry
+}
 
-func (j raftJoiner) Join(nodeID, addr string) error {
-    // 若已存在则忽略
+func (j raftJoiner) Join(nodeID, addr, httpAddr string) error {
+    // 若已存在则仅更新 HTTP 地址
     cfgFuture := j.Raft.GetConfiguration()
     if err := cfgFuture.Error(); err != nil { return err }
+    exists := false
     for _, s := range cfgFuture.Configuration().Servers {
         if s.ID == hraft.ServerID(nodeID) || s.Address == hraft.ServerAddress(addr) {
-            return nil
+            exists = true
+            break
         }
     }
-    f := j.Raft.AddVoter(hraft.ServerID(nodeID), hraft.ServerAddress(addr), 0, 0)
-    return f.Error()
+    if !exists {
+        f := j.Raft.AddVoter(hraft.ServerID(nodeID), hraft.ServerAddress(addr), 0, 0)
+        if err := f.Error(); err != nil { return err }
+    }
+    if httpAddr == "" { return nil }
+    return j.Reg.SetServerHTTPAddr(nodeID, httpAddr)
+}
+
+// raftLeader 根据 Raft 当前 Leader 的 ID 查找其公布的 HTTP 地址。
+type raftLeader struct {
+    Raft *hraft.Raft
+    Reg  *registry.RaftRegistry
+}
+
+func (l raftLeader) LeaderHTTPAddr() (string, bool) {
+    if l.Raft.State() == hraft.Leader { return "", true }
+    _, id := l.Raft.LeaderWithID()
+    if id == "" { return "", false }
+    return l.Reg.ServerHTTPAddr(string(id)), false
+}
+
+func (l raftLeader) VerifyLeader() error { return l.Raft.VerifyLeader().Error() }
+
+func (l raftLeader) KnownLeader() bool {
+    addr, _ := l.Raft.LeaderWithID()
+    return addr != ""
+}
+
+func (l raftLeader) LastContact() time.Duration {
+    if l.Raft.State() == hraft.Leader { return 0 }
+    last := l.Raft.LastContact()
+    if last.IsZero() { return 0 }
+    return time.Since(last)
 }